@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Role grants access to a group of endpoints.
+type Role string
+
+// The roles of the access control. A deployer has all roles, installers and approvers are also viewers.
+const (
+	RoleViewer    Role = "viewer"
+	RoleInstaller Role = "installer"
+	RoleApprover  Role = "approver"
+	RoleDeployer  Role = "deployer"
+)
+
+// implied lists the roles granted by a role.
+var implied = map[Role][]Role{
+	RoleViewer:    {RoleViewer},
+	RoleInstaller: {RoleInstaller, RoleViewer},
+	RoleApprover:  {RoleApprover, RoleViewer},
+	RoleDeployer:  {RoleDeployer, RoleInstaller, RoleApprover, RoleViewer},
+}
+
+// access is the access control of the endpoints, nil disables it.
+var access *AccessControl
+
+// AccessControl maps the callers of the api to roles. Callers are identified by a verified tls client certificate
+// (cert:{common name}), a bearer token (token:{name}) or a json web token signed by a key of the jwks (jwt:{subject}).
+type AccessControl struct {
+	Tokens   []AccessToken `json:"tokens,omitempty"`
+	OIDC     *OIDCConfig   `json:"oidc,omitempty"`
+	Bindings []RoleBinding `json:"bindings"`
+
+	jwks map[string]crypto.PublicKey
+}
+
+// AccessToken is a static bearer token. Only the hex encoded sha256 of the token is configured.
+type AccessToken struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// OIDCConfig describes how json web tokens issued by an openid connect provider are validated.
+type OIDCConfig struct {
+	JWKSFile     string `json:"jwks_file"`
+	Issuer       string `json:"issuer,omitempty"`
+	Audience     string `json:"audience,omitempty"`
+	SubjectClaim string `json:"subject_claim,omitempty"` // defaults to sub
+}
+
+// RoleBinding grants roles to subjects on the channels and chaincodes matching the patterns, e.g. fabcar-*. Empty
+// patterns match all channels or chaincodes, endpoints without channel or chaincode are not restricted by them.
+type RoleBinding struct {
+	Subjects   []string `json:"subjects"`
+	Roles      []Role   `json:"roles"`
+	Channels   []string `json:"channels,omitempty"`
+	Chaincodes []string `json:"chaincodes,omitempty"`
+}
+
+// Identity is an identified caller of the api.
+type Identity struct {
+	Subject string
+}
+
+// LoadAccessControl reads and validates the access control file.
+func LoadAccessControl(filename string) (*AccessControl, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &AccessControl{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("Invalid access control %v: %v", filename, err)
+	}
+	for _, token := range config.Tokens {
+		if token.Name == "" || len(token.SHA256) != sha256.Size*2 {
+			return nil, fmt.Errorf("Invalid token %v, expected a name and a hex encoded sha256", token.Name)
+		}
+	}
+	for _, binding := range config.Bindings {
+		for _, role := range binding.Roles {
+			if _, ok := implied[role]; !ok {
+				return nil, fmt.Errorf("Invalid role %v", role)
+			}
+		}
+		patterns := append(append(append([]string{}, binding.Subjects...), binding.Channels...), binding.Chaincodes...)
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("Invalid pattern %v: %v", pattern, err)
+			}
+		}
+	}
+	if config.OIDC != nil {
+		if config.jwks, err = LoadJWKS(config.OIDC.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// identify returns the identity of the caller or nil if the request carries no credentials.
+func (a *AccessControl) identify(req *http.Request) (*Identity, error) {
+	if header := req.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token := strings.TrimPrefix(header, "Bearer ")
+		digest := sha256.Sum256([]byte(token))
+		for _, t := range a.Tokens {
+			expected, err := hex.DecodeString(t.SHA256)
+			if err == nil && subtle.ConstantTimeCompare(digest[:], expected) == 1 {
+				return &Identity{Subject: "token:" + t.Name}, nil
+			}
+		}
+		if a.OIDC != nil && strings.Count(token, ".") == 2 {
+			return a.identifyJWT(token)
+		}
+		return nil, fmt.Errorf("Unknown bearer token")
+	}
+
+	// only client certificates verified against SERVER_TLS_CLIENT_CA_FILES identify a caller.
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		return &Identity{Subject: "cert:" + req.TLS.VerifiedChains[0][0].Subject.CommonName}, nil
+	}
+	return nil, nil
+}
+
+func (a *AccessControl) identifyJWT(token string) (*Identity, error) {
+	claims, err := verifyJWT(token, a.jwks)
+	if err != nil {
+		return nil, err
+	}
+	if a.OIDC.Issuer != "" && claims["iss"] != a.OIDC.Issuer {
+		return nil, fmt.Errorf("Token was not issued by %v", a.OIDC.Issuer)
+	}
+	if a.OIDC.Audience != "" && !hasAudience(claims, a.OIDC.Audience) {
+		return nil, fmt.Errorf("Token is not intended for %v", a.OIDC.Audience)
+	}
+
+	claim := a.OIDC.SubjectClaim
+	if claim == "" {
+		claim = "sub"
+	}
+	subject, ok := claims[claim].(string)
+	if !ok || subject == "" {
+		return nil, fmt.Errorf("Token has no %v claim", claim)
+	}
+	return &Identity{Subject: "jwt:" + subject}, nil
+}
+
+// allowed checks if one of the bindings of the identity grants the role on the channel and chaincode.
+func (a *AccessControl) allowed(identity *Identity, role Role, channel, chaincode string) bool {
+	for _, binding := range a.Bindings {
+		if matchAny(binding.Subjects, identity.Subject) && grants(binding.Roles, role) &&
+			(channel == "" || len(binding.Channels) == 0 || matchAny(binding.Channels, channel)) &&
+			(chaincode == "" || len(binding.Chaincodes) == 0 || matchAny(binding.Chaincodes, chaincode)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func grants(roles []Role, role Role) bool {
+	for _, r := range roles {
+		for _, granted := range implied[r] {
+			if granted == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routeScope returns the channel and chaincode of the route.
+func routeScope(req *http.Request) (string, string) {
+	vars := mux.Vars(req)
+	return vars["channel"], vars["chaincode"]
+}
+
+// approvalScope returns the channel and chaincode of the pending approval of the route.
+func approvalScope(req *http.Request) (string, string) {
+	approval, _ := pending.Get(mux.Vars(req)["id"])
+	return approval.Channel, approval.Chaincode
+}
+
+// authorize checks the role of the caller. Returns the identified caller and the status code to reject the request
+// with if the caller does not have the role.
+func authorize(req *http.Request, role Role, scope func(*http.Request) (string, string)) (*Identity, int, error) {
+	identity, err := access.identify(req)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+	if identity == nil {
+		return nil, http.StatusUnauthorized, fmt.Errorf("Missing credentials")
+	}
+
+	channel, chaincode := scope(req)
+	if !access.allowed(identity, role, channel, chaincode) {
+		return identity, http.StatusForbidden, fmt.Errorf("%v is not a %v of %v", identity.Subject, role, req.URL.Path)
+	}
+	return identity, 0, nil
+}
+
+// Authorize only passes requests of callers having the role on the channel and chaincode of the route. Passes all
+// requests if the access control is disabled.
+func Authorize(role Role, next http.HandlerFunc) http.Handler {
+	return AuthorizeScope(role, routeScope, next)
+}
+
+// AuthorizeScope only passes requests of callers having the role on the channel and chaincode returned by scope.
+func AuthorizeScope(role Role, scope func(*http.Request) (string, string), next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if access == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if _, status, err := authorize(req, role, scope); err != nil {
+			logger.Warnf("Denied %v %v from %v: %v", req.Method, req.URL.Path, req.RemoteAddr, err)
+			http.Error(w, fmt.Sprintf("Error: %v", err.Error()), status)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// AuthorizeOrganization passes requests of callers having the role like Authorize. Requests of other callers have to
+// be sent by another organization of the channel, see RequireOrganization, and are denied if the authentication of
+// other organizations is disabled. Callers having the role act on behalf of the local organization.
+func AuthorizeOrganization(role Role, next http.HandlerFunc) http.Handler {
+	organization := RequireOrganization(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if access == nil {
+			organization.ServeHTTP(w, req)
+			return
+		}
+
+		identity, status, err := authorize(req, role, routeScope)
+		if err != nil && os.Getenv("REMOTE_AUTH") == "disabled" {
+			logger.Warnf("Denied %v %v from %v: %v", req.Method, req.URL.Path, req.RemoteAddr, err)
+			http.Error(w, fmt.Sprintf("Error: %v", err.Error()), status)
+			return
+		}
+		if err != nil {
+			if identity != nil {
+				logger.Debugf("Authenticating %v as organization: %v", identity.Subject, err)
+			}
+			organization.ServeHTTP(w, req)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), requesterKey{}, os.Getenv("CORE_PEER_LOCALMSPID"))))
+	})
+}
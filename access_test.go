@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAuthorizeOrganization(t *testing.T) {
+	digest := func(token string) string {
+		sum := sha256.Sum256([]byte(token))
+		return hex.EncodeToString(sum[:])
+	}
+	control := &AccessControl{
+		Tokens: []AccessToken{{Name: "ci", SHA256: digest("ci-secret")}, {Name: "ops", SHA256: digest("ops-secret")}},
+		Bindings: []RoleBinding{
+			{Subjects: []string{"token:ci"}, Roles: []Role{RoleInstaller}},
+			{Subjects: []string{"token:ops"}, Roles: []Role{RoleViewer}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		remoteAuth string
+		token      string
+		status     int
+		requester  string
+	}{
+		{name: "installer", token: "ci-secret", status: http.StatusOK, requester: "Org1MSP"},
+		{name: "installer without remote auth", remoteAuth: "disabled", token: "ci-secret", status: http.StatusOK, requester: "Org1MSP"},
+		{name: "viewer without remote auth", remoteAuth: "disabled", token: "ops-secret", status: http.StatusForbidden},
+		{name: "anonymous without remote auth", remoteAuth: "disabled", status: http.StatusUnauthorized},
+		{name: "anonymous without signature", status: http.StatusUnauthorized},
+	}
+
+	previousAccess, previousRemoteAuth := access, os.Getenv("REMOTE_AUTH")
+	defer func() {
+		access = previousAccess
+		os.Setenv("REMOTE_AUTH", previousRemoteAuth)
+	}()
+	access = control
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restore := newTestLifecycle(t, &FakeRunner{}, nil)
+			defer restore()
+			os.Setenv("REMOTE_AUTH", test.remoteAuth)
+
+			var requested string
+			handler := AuthorizeOrganization(RoleInstaller, func(w http.ResponseWriter, req *http.Request) {
+				requested = requester(req)
+			})
+			req := httptest.NewRequest("POST", "/install/mycc", nil)
+			req = mux.SetURLVars(req, map[string]string{"chaincode": "mycc"})
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.status {
+				t.Errorf("expected status %v, got %v: %v", test.status, w.Code, w.Body.String())
+			}
+			if requested != test.requester {
+				t.Errorf("expected requester %q, got %q", test.requester, requested)
+			}
+		})
+	}
+}
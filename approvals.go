@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// The decisions on pending approvals.
+const (
+	DecisionAccepted = "accepted"
+	DecisionRejected = "rejected"
+)
+
+// pending keeps the approval requests which did not match the approval policy.
+var pending = NewApprovalQueue("")
+
+// PendingApproval is an approval request of another organization waiting for manual consent.
+type PendingApproval struct {
+	ID         string              `json:"id"`
+	Requester  string              `json:"requester,omitempty"`
+	Channel    string              `json:"channel"`
+	Chaincode  string              `json:"chaincode"`
+	Sequence   int                 `json:"sequence"`
+	CCID       string              `json:"ccid"`
+	Definition ChaincodeDefinition `json:"definition"`
+	Reason     string              `json:"reason"`
+	Created    time.Time           `json:"created"`
+}
+
+// PendingError is returned if an approval request has been parked for manual consent.
+type PendingError struct {
+	Approval *PendingApproval
+}
+
+func (e *PendingError) Error() string {
+	return fmt.Sprintf("Approval of %v with ccid %v[%v] on %v is pending: %v", e.Approval.Chaincode, e.Approval.CCID,
+		e.Approval.Sequence, e.Approval.Channel, e.Approval.Reason)
+}
+
+// DecisionRequest represents the optional json body of the accept and reject endpoints.
+type DecisionRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// Decision notifies the requesting organization about the decision on its approval request.
+type Decision struct {
+	MSPID    string          `json:"mspid"`
+	Decision string          `json:"decision"`
+	Reason   string          `json:"reason,omitempty"`
+	Approval PendingApproval `json:"approval"`
+}
+
+// ApprovalQueue keeps the pending approvals. Changes are written back to the queue file if the queue has been loaded
+// from one.
+type ApprovalQueue struct {
+	mu        sync.Mutex
+	path      string
+	approvals map[string]*PendingApproval
+}
+
+// NewApprovalQueue builds an empty approval queue persisted to path.
+func NewApprovalQueue(path string) *ApprovalQueue {
+	return &ApprovalQueue{path: path, approvals: make(map[string]*PendingApproval)}
+}
+
+// LoadApprovalQueue reads the pending approvals from the json file at path. A missing file results in an empty queue.
+func LoadApprovalQueue(path string) (*ApprovalQueue, error) {
+	q := NewApprovalQueue(path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+		return nil, err
+	}
+
+	var approvals []*PendingApproval
+	if err := json.Unmarshal(data, &approvals); err != nil {
+		return nil, fmt.Errorf("Invalid approval queue %v: %v", path, err)
+	}
+	for _, approval := range approvals {
+		q.approvals[approval.ID] = approval
+	}
+	return q, nil
+}
+
+// Add parks the approval. A repeated request of the same approval returns the already pending approval.
+func (q *ApprovalQueue) Add(approval PendingApproval) (*PendingApproval, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, p := range q.approvals {
+		if p.Requester == approval.Requester && p.Channel == approval.Channel && p.Chaincode == approval.Chaincode &&
+			p.Sequence == approval.Sequence && p.CCID == approval.CCID {
+			return p, nil
+		}
+	}
+
+	approval.ID = uuid.New().String()
+	approval.Created = time.Now()
+	q.approvals[approval.ID] = &approval
+	if err := q.save(); err != nil {
+		delete(q.approvals, approval.ID)
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// Get returns the pending approval with the given id.
+func (q *ApprovalQueue) Get(id string) (PendingApproval, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	approval, ok := q.approvals[id]
+	if !ok {
+		return PendingApproval{}, false
+	}
+	return *approval, true
+}
+
+// List returns the pending approvals, the oldest first.
+func (q *ApprovalQueue) List() []PendingApproval {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.list()
+}
+
+func (q *ApprovalQueue) list() []PendingApproval {
+	approvals := []PendingApproval{}
+	for _, approval := range q.approvals {
+		approvals = append(approvals, *approval)
+	}
+	sort.Slice(approvals, func(i, j int) bool { return approvals[i].Created.Before(approvals[j].Created) })
+	return approvals
+}
+
+// Remove drops the pending approval. Returns false if it is not pending.
+func (q *ApprovalQueue) Remove(id string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	previous, ok := q.approvals[id]
+	if !ok {
+		return false, nil
+	}
+	delete(q.approvals, id)
+	if err := q.save(); err != nil {
+		q.approvals[id] = previous
+		return true, err
+	}
+	return true, nil
+}
+
+func (q *ApprovalQueue) save() error {
+	if q.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(q.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0700); err != nil {
+		return err
+	}
+	// the queue contains the chaincode definitions of the requesters.
+	return ioutil.WriteFile(q.path, data, 0600)
+}
+
+// lifecycle builds the lifecycle approving the pending approval.
+func (a PendingApproval) lifecycle(ctx context.Context) Lifecycle {
+	lifecycle := NewLifecycle(ctx, map[string]string{"channel": a.Channel, "chaincode": a.Chaincode, "ccid": a.CCID})
+	lifecycle.Sequence = a.Sequence
+	lifecycle.Definition = a.Definition
+	lifecycle.Requester = a.Requester
+	return lifecycle
+}
+
+// notify sends the decision on the approval to the lifecycle service of the requesting organization. Failures are
+// only logged as the decision has already been taken.
+func (l *Lifecycle) notify(approval PendingApproval, decision, reason string) {
+	if approval.Requester == "" {
+		logger.Warnf("Cannot notify the unknown requester of approval %v", approval.ID)
+		return
+	}
+
+	err := func() error {
+		body, err := json.Marshal(Decision{MSPID: l.MSPID, Decision: decision, Reason: reason, Approval: approval})
+		if err != nil {
+			return err
+		}
+		if err := l.Discover(); err != nil {
+			return err
+		}
+		for _, node := range l.leaders() {
+			if node.MSPID != approval.Requester {
+				continue
+			}
+			resp, err := l.post(node, fmt.Sprintf("/%v/decisions/%v", l.Channel, l.Chaincode), body)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != 200 {
+				return fmt.Errorf("%v returned status code %v", node.MSPID, resp.StatusCode)
+			}
+			return nil
+		}
+		return fmt.Errorf("%v is not a member of %v", approval.Requester, l.Channel)
+	}()
+	if err != nil {
+		logger.Warnf("Failed to notify %v about the %v approval %v: %v", approval.Requester, decision, approval.ID, err)
+	}
+}
+
+// GetPendingApprovals returns the approval requests waiting for manual consent.
+func GetPendingApprovals(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pending.List()); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+	}
+}
+
+// AcceptApproval approves the pending approval and notifies the requesting organization. The approval stays pending
+// if it fails.
+func AcceptApproval(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	approval, ok := pending.Get(id)
+	if !ok {
+		logger.Warnf("Approval %v is not pending", id)
+		http.Error(w, fmt.Sprintf("Approval %v is not pending", id), http.StatusNotFound)
+		return
+	}
+
+	var request DecisionRequest
+	if err := decodeRequest(req, &request); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	lifecycle := approval.lifecycle(req.Context())
+	lifecycle.consented = true
+	if err := lifecycle.approve(); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if _, err := pending.Remove(id); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("Accepted approval %v of %v with ccid %v[%v] on %v requested by %v", id, approval.Chaincode,
+		approval.CCID, approval.Sequence, approval.Channel, approval.Requester)
+
+	lifecycle.notify(approval, DecisionAccepted, request.Reason)
+}
+
+// RejectApproval drops the pending approval and notifies the requesting organization.
+func RejectApproval(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	approval, ok := pending.Get(id)
+	if !ok {
+		logger.Warnf("Approval %v is not pending", id)
+		http.Error(w, fmt.Sprintf("Approval %v is not pending", id), http.StatusNotFound)
+		return
+	}
+
+	var request DecisionRequest
+	if err := decodeRequest(req, &request); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := pending.Remove(id); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("Rejected approval %v of %v with ccid %v[%v] on %v requested by %v", id, approval.Chaincode,
+		approval.CCID, approval.Sequence, approval.Channel, approval.Requester)
+
+	lifecycle := approval.lifecycle(req.Context())
+	lifecycle.notify(approval, DecisionRejected, request.Reason)
+}
+
+// Decide receives the decision of another organization on a parked approval request and reports it to the running
+// deployments of the chaincode approving the same ccid and sequence. A rejection fails the deployments waiting for the
+// approval.
+func Decide(w http.ResponseWriter, req *http.Request) {
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
+
+	var decision Decision
+	if err := decodeRequest(req, &decision); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if mspID := requester(req); mspID != "" {
+		decision.MSPID = mspID
+	}
+	if decision.Decision != DecisionAccepted && decision.Decision != DecisionRejected {
+		logger.Warnf("Invalid decision %v of %v", decision.Decision, decision.MSPID)
+		http.Error(w, fmt.Sprintf("Invalid decision %v", decision.Decision), http.StatusBadRequest)
+		return
+	}
+
+	logger.Infof("%v %v the approval of %v with ccid %v[%v] on %v", decision.MSPID, decision.Decision,
+		lifecycle.Chaincode, decision.Approval.CCID, decision.Approval.Sequence, lifecycle.Channel)
+	for _, job := range jobs.Running(lifecycle.Channel, lifecycle.Chaincode) {
+		// decisions on approvals of earlier deployments must not affect the running ones.
+		if job.awaits(decision.Approval) {
+			job.decide(decision)
+		}
+	}
+}
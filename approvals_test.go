@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDecide(t *testing.T) {
+	ccid := "mycc:6f1ed002ab5595859014ebf0951522d9e9b2a8e1c6d5f3b7a0e4c9d8b2f1a3c5"
+	tests := []struct {
+		name     string
+		decision Decision
+		rejected bool
+		parked   int
+	}{
+		{
+			name:     "rejection of the running approval",
+			decision: Decision{Decision: DecisionRejected, Approval: PendingApproval{CCID: ccid, Sequence: 2}},
+			rejected: true,
+		},
+		{
+			name:     "acceptance of the running approval",
+			decision: Decision{Decision: DecisionAccepted, Approval: PendingApproval{CCID: ccid, Sequence: 2}},
+		},
+		{
+			name:     "rejection of an earlier sequence",
+			decision: Decision{Decision: DecisionRejected, Approval: PendingApproval{CCID: ccid, Sequence: 1}},
+			parked:   1,
+		},
+		{
+			name:     "rejection of another package",
+			decision: Decision{Decision: DecisionRejected, Approval: PendingApproval{CCID: "mycc:0000", Sequence: 2}},
+			parked:   1,
+		},
+	}
+
+	previous := jobs
+	defer func() { jobs = previous }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jobs = NewJobs()
+			job := jobs.Add("mychannel", "mycc")
+			job.approving(ccid, 2)
+			job.park("Org2MSP")
+
+			body, err := json.Marshal(test.decision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("POST", "/mychannel/decisions/mycc", bytes.NewReader(body))
+			req = mux.SetURLVars(req, map[string]string{"channel": "mychannel", "chaincode": "mycc"})
+			req = req.WithContext(context.WithValue(req.Context(), requesterKey{}, "Org2MSP"))
+			w := httptest.NewRecorder()
+			Decide(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("unexpected status %v: %v", w.Code, w.Body.String())
+			}
+			if rejected := job.rejection() != nil; rejected != test.rejected {
+				t.Errorf("expected rejected %v, got %v", test.rejected, job.rejection())
+			}
+			if parked := job.parkedApprovals(); parked != test.parked {
+				t.Errorf("expected %v parked approvals, got %v", test.parked, parked)
+			}
+		})
+	}
+}
+
+func TestApprovalQueueSurvivesRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "work", "approvals.json")
+
+	queue, err := LoadApprovalQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approval, err := queue.Add(PendingApproval{Requester: "Org2MSP", Channel: "mychannel", Chaincode: "mycc", CCID: "mycc:abc", Sequence: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("expected mode 0600, got %v", mode)
+	}
+
+	restarted, err := LoadApprovalQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored, ok := restarted.Get(approval.ID); !ok || restored.CCID != "mycc:abc" || restored.Requester != "Org2MSP" {
+		t.Errorf("expected approval %v to be restored, got %+v", approval.ID, restored)
+	}
+}
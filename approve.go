@@ -4,32 +4,54 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
-	"strings"
 )
 
+// ApproveRequest represents the optional json body of the approve endpoint.
+type ApproveRequest struct {
+	Definition ChaincodeDefinition `json:"definition"`
+}
+
 // Approve approves the given chaincode with ccid in the network. Performs a http request for each msp which is not the current.
 func (l *Lifecycle) Approve() error {
-	for _, node := range l.Nodes {
-		if node.Name != "peer-0" {
-			continue
-		}
-		if node.MSPID == l.MSPID {
-			// if msp is local msp, no need to make an http request
-			if err := l.approve(); err != nil {
-				return err
+	body, err := json.Marshal(ApproveRequest{Definition: l.Definition})
+	if err != nil {
+		return err
+	}
+
+	l.job.approving(l.CCID, l.Sequence)
+	for _, node := range l.leaders() {
+		var approval *PendingApproval
+		err := l.job.Run("approve", node.MSPID, func() error {
+			if node.MSPID == l.MSPID {
+				// if msp is local msp, no need to make an http request
+				return l.approve()
 			}
-		} else {
 			// ask participants to approve the chaincode installation
-			resp, err := http.Get(fmt.Sprintf("%v://lifecycle.%v:%v/%v/approve/%v/%v/%v", "http", node.Host, "8090", l.Channel, l.Chaincode, l.Sequence, l.CCID))
+			resp, err := l.post(node, fmt.Sprintf("/%v/approve/%v/%v/%v", l.Channel, l.Chaincode, l.Sequence, l.CCID), body)
 			if err != nil {
 				return err
 			}
+			defer resp.Body.Close()
+			if resp.StatusCode == http.StatusAccepted {
+				// the approval has been parked for manual consent, the commit waits for it.
+				approval = &PendingApproval{}
+				return json.NewDecoder(resp.Body).Decode(approval)
+			}
 			if resp.StatusCode != 200 {
 				return fmt.Errorf("%v returned status code %v", node.MSPID, resp.StatusCode)
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-		logger.Infof("%v approved the chaincode installation", node.MSPID)
+		if approval != nil {
+			l.job.park(node.MSPID)
+			l.job.Infof("%v parked the approval for manual consent: %v", node.MSPID, approval.Reason)
+			continue
+		}
+		l.approvers = append(l.approvers, node.MSPID)
+		l.job.Infof("%v approved the chaincode installation", node.MSPID)
 	}
 
 	return nil
@@ -42,50 +64,22 @@ func (l *Lifecycle) approve() error {
 		return nil
 	}
 
-	command := []string{
-		"peer lifecycle chaincode approveformyorg",
-		fmt.Sprintf("--channelID %v", l.Channel),
-		fmt.Sprintf("--name %v", l.Chaincode),
-		fmt.Sprintf("--version %v", "1.0"),
-		fmt.Sprintf("--package-id %v", l.CCID),
-		fmt.Sprintf("--sequence %v", l.Sequence),
-		fmt.Sprintf("-o %v", os.Getenv("ORDERER_ADDRESS")),
-		fmt.Sprintf("--tls %v", "true"),
-		fmt.Sprintf("--cafile %v", os.Getenv("ORDERER_CA")),
+	// approval requests of other organizations need their consent.
+	if err := l.consent(); err != nil {
+		return err
 	}
-	// "--init-required"
 
 	// approve chaincode installation
-	_, err := l.execute(strings.Join(command, " "))
-	return err
+	return l.Backend.ApproveForMyOrg(l.context(), l.Channel, l.Chaincode, l.Sequence, l.CCID, l.Definition)
 }
 
 func (l *Lifecycle) checkIfChaincodeIsApproved() bool {
-	command := []string{
-		"peer lifecycle chaincode checkcommitreadiness",
-		fmt.Sprintf("--channelID %v", l.Channel),
-		fmt.Sprintf("--name %v", l.Chaincode),
-		fmt.Sprintf("--version %v", "1.0"),
-		fmt.Sprintf("--sequence %v", l.Sequence),
-		fmt.Sprintf("-o %v", os.Getenv("ORDERER_ADDRESS")),
-		fmt.Sprintf("--tls %v", "true"),
-		fmt.Sprintf("--cafile %v", os.Getenv("ORDERER_CA")),
-		"-O json",
-	}
-	// "--init-required"
-
 	// check if the chaincode has already been approved.
-	response, err := l.execute(strings.Join(command, " "))
+	approvals, err := l.Backend.CheckCommitReadiness(l.context(), l.Channel, l.Chaincode, l.Sequence, l.Definition)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
 		return false
 	}
 
-	var approvals map[string]interface{}
-	if err := json.Unmarshal(response.Output.Bytes(), &approvals); err != nil {
-		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
-		return false
-	}
-
-	return approvals["approvals"].(map[string]interface{})[l.MSPID].(bool)
+	return approvals[l.MSPID]
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestApprove(t *testing.T) {
+	readiness := []string{
+		"peer", "lifecycle", "chaincode", "checkcommitreadiness",
+		"--channelID", "mychannel",
+		"--name", "mycc",
+		"--sequence", "2",
+		"-o", "orderer.example.com:7050",
+		"--tls",
+		"--cafile", "/certs/orderer-ca.pem",
+		"-O", "json",
+		"--version", "1.0",
+		"--signature-policy", "OR('Org1MSP.peer','Org2MSP.peer')",
+	}
+	approval := []string{
+		"peer", "lifecycle", "chaincode", "approveformyorg",
+		"--channelID", "mychannel",
+		"--name", "mycc",
+		"--package-id", "mycc:" + strings.Repeat("a", 64),
+		"--sequence", "2",
+		"-o", "orderer.example.com:7050",
+		"--tls",
+		"--cafile", "/certs/orderer-ca.pem",
+		"--version", "1.0",
+		"--signature-policy", "OR('Org1MSP.peer','Org2MSP.peer')",
+	}
+
+	tests := []struct {
+		name      string
+		mspID     string
+		readiness string
+		err       error
+		approved  bool
+		approvals [][]string
+	}{
+		{
+			name:      "not yet approved",
+			readiness: recorded(t, "checkcommitreadiness.json"),
+			approvals: [][]string{approval},
+		},
+		{
+			name:      "already approved",
+			mspID:     "Org2MSP",
+			readiness: recorded(t, "checkcommitreadiness.json"),
+			approved:  true,
+		},
+		{
+			name:      "readiness unknown",
+			readiness: "",
+			err:       fmt.Errorf("exit status 1"),
+			approvals: [][]string{approval},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := (&FakeRunner{}).
+				On(test.readiness, "", test.err, "peer", "lifecycle", "chaincode", "checkcommitreadiness").
+				On("", "", nil, "peer", "lifecycle", "chaincode", "approveformyorg")
+			lifecycle, restore := newTestLifecycle(t, fake, map[string]string{
+				"sequence": "2",
+				"ccid":     "mycc:" + strings.Repeat("a", 64),
+			})
+			defer restore()
+			if test.mspID != "" {
+				lifecycle.MSPID, lifecycle.Requester = test.mspID, test.mspID
+			}
+			lifecycle.Definition.SignaturePolicy = "OR('Org1MSP.peer','Org2MSP.peer')"
+
+			if approved := lifecycle.checkIfChaincodeIsApproved(); approved != test.approved {
+				t.Errorf("expected approved %v, got %v", test.approved, approved)
+			}
+			if err := lifecycle.approve(); err != nil {
+				t.Fatal(err)
+			}
+
+			checks := fake.Commands("peer", "lifecycle", "chaincode", "checkcommitreadiness")
+			if len(checks) != 2 {
+				t.Fatalf("expected 2 readiness checks, got %v", len(checks))
+			}
+			for _, command := range checks {
+				assertCommand(t, command, readiness)
+			}
+			approvals := fake.Commands("peer", "lifecycle", "chaincode", "approveformyorg")
+			if len(approvals) != len(test.approvals) {
+				t.Fatalf("expected %v approvals, got %v", len(test.approvals), len(approvals))
+			}
+			for i, command := range approvals {
+				assertCommand(t, command, test.approvals[i])
+			}
+		})
+	}
+}
+
+func TestApproveRequiresConsent(t *testing.T) {
+	fake := (&FakeRunner{}).
+		On(recorded(t, "checkcommitreadiness.json"), "", nil, "peer", "lifecycle", "chaincode", "checkcommitreadiness").
+		On("", "", nil, "peer", "lifecycle", "chaincode", "approveformyorg")
+	lifecycle, restore := newTestLifecycle(t, fake, map[string]string{"ccid": "mycc:" + strings.Repeat("a", 64)})
+	defer restore()
+	previousPolicy, previousPending := approvalPolicy, pending
+	defer func() { approvalPolicy, pending = previousPolicy, previousPending }()
+	approvalPolicy = &ApprovalPolicy{Manual: true}
+	pending = NewApprovalQueue("")
+	lifecycle.Requester = "Org2MSP"
+
+	err := lifecycle.approve()
+	if _, ok := err.(*PendingError); !ok {
+		t.Fatalf("expected a pending approval, got %v", err)
+	}
+	if approvals := fake.Commands("peer", "lifecycle", "chaincode", "approveformyorg"); len(approvals) != 0 {
+		t.Errorf("expected no approval, got %v", approvals)
+	}
+	if parked := pending.List(); len(parked) != 1 || parked[0].Requester != "Org2MSP" {
+		t.Errorf("unexpected pending approvals %+v", parked)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The headers of signed requests between lifecycle services.
+const (
+	headerMSPID       = "X-Lifecycle-Mspid"
+	headerChannel     = "X-Lifecycle-Channel"
+	headerTimestamp   = "X-Lifecycle-Timestamp"
+	headerCertificate = "X-Lifecycle-Certificate"
+	headerSignature   = "X-Lifecycle-Signature"
+	headerNonce       = "X-Lifecycle-Nonce"
+)
+
+// signatureWindow defines how far the timestamp of a signed request may deviate from the local time.
+const signatureWindow = 5 * time.Minute
+
+// maxSignedBodySize limits the size of signed request bodies held in memory, e.g. forwarded source archives.
+const maxSignedBodySize = maxRequestSize
+
+// maxNonceLength limits the length of the nonces of signed requests.
+const maxNonceLength = 64
+
+// nonces keeps the nonces of the signed requests within the signature window to reject replayed requests.
+var nonces = NewNonceCache()
+
+type requesterKey struct{}
+
+// AuthError is returned if a request of another lifecycle service cannot be authenticated or is not allowed.
+type AuthError struct {
+	Status  int
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// NonceCache remembers nonces until they expire.
+type NonceCache struct {
+	mu    sync.Mutex
+	nonce map[string]time.Time
+}
+
+// NewNonceCache builds an empty nonce cache.
+func NewNonceCache() *NonceCache {
+	return &NonceCache{nonce: make(map[string]time.Time)}
+}
+
+// Add remembers the nonce until it expires. Returns false if the nonce is already known.
+func (c *NonceCache) Add(nonce string, expires time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for n, e := range c.nonce {
+		if now.After(e) {
+			delete(c.nonce, n)
+		}
+	}
+	if _, ok := c.nonce[nonce]; ok {
+		return false
+	}
+	c.nonce[nonce] = expires
+	return true
+}
+
+// signRequest signs the request with the identity of the local msp. The signature covers the method, the path, the
+// channel, the timestamp, a random nonce and the body of the request.
+func signRequest(req *http.Request, channel string, body []byte) error {
+	signer, err := NewSigner(os.Getenv("CORE_PEER_LOCALMSPID"), os.Getenv("CORE_PEER_MSPCONFIGPATH"))
+	if err != nil {
+		return err
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(random)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature, err := signer.Sign(signedMessage(req.Method, req.URL.RequestURI(), signer.MSPID, channel, timestamp, nonce, body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(headerMSPID, signer.MSPID)
+	req.Header.Set(headerChannel, channel)
+	req.Header.Set(headerTimestamp, timestamp)
+	req.Header.Set(headerNonce, nonce)
+	req.Header.Set(headerCertificate, base64.StdEncoding.EncodeToString(signer.Cert))
+	req.Header.Set(headerSignature, base64.StdEncoding.EncodeToString(signature))
+	return nil
+}
+
+func signedMessage(method, uri, mspID, channel, timestamp, nonce string, body []byte) []byte {
+	digest := sha256.Sum256(body)
+	return []byte(fmt.Sprintf("%v\n%v\n%v\n%v\n%v\n%v\n%x", method, uri, mspID, channel, timestamp, nonce, digest))
+}
+
+// RequireOrganization only passes requests of lifecycle services of other organizations of the channel. Requests are
+// either sent with a tls client certificate of the msp or signed by an admin or peer identity of the requesting msp.
+// Certificates are verified against the msp roots found by the discovery, signed requests must not be replayed. Can be
+// disabled by setting REMOTE_AUTH to disabled.
+func RequireOrganization(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if os.Getenv("REMOTE_AUTH") == "disabled" {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		mspID, err := authenticate(w, req)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if e, ok := err.(*AuthError); ok {
+				status = e.Status
+			}
+			logger.Warnf("Rejected %v %v from %v: %v", req.Method, req.URL.Path, req.RemoteAddr, err)
+			http.Error(w, fmt.Sprintf("Error: %v", err.Error()), status)
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), requesterKey{}, mspID)))
+	})
+}
+
+// requester returns the msp id of the lifecycle service which sent the request or an empty string if the request
+// has not been authenticated.
+func requester(req *http.Request) string {
+	mspID, _ := req.Context().Value(requesterKey{}).(string)
+	return mspID
+}
+
+// authenticate verifies the tls client certificate or the signature of the request and returns the msp id of the
+// requester.
+func authenticate(w http.ResponseWriter, req *http.Request) (string, error) {
+	channel := mux.Vars(req)["channel"]
+	if header := req.Header.Get(headerChannel); channel == "" {
+		channel = header
+	} else if header != "" && header != channel {
+		return "", &AuthError{http.StatusUnauthorized, fmt.Sprintf("Request was signed for channel %v", header)}
+	}
+	if channel == "" {
+		return "", &AuthError{http.StatusUnauthorized, "Missing channel of the requesting organization"}
+	}
+	certified := req.TLS != nil && len(req.TLS.PeerCertificates) > 0
+	if !certified && req.Header.Get(headerSignature) == "" {
+		return "", &AuthError{http.StatusUnauthorized, "Missing signature of the requesting organization"}
+	}
+
+	lifecycle := NewLifecycle(req.Context(), map[string]string{"channel": channel})
+	discovery, err := lifecycle.discover()
+	if err != nil {
+		return "", &AuthError{http.StatusInternalServerError, fmt.Sprintf("Failed to discover %v: %v", channel, err)}
+	}
+
+	if certified {
+		mspID, err := authenticateTLS(req, discovery.Config)
+		if err == nil || req.Header.Get(headerSignature) == "" {
+			return mspID, err
+		}
+		// e.g. the client certificate of a proxy, the request may still be signed by the organization.
+		logger.Debugf("Verifying the signature of %v %v: %v", req.Method, req.URL.Path, err)
+	}
+	return authenticateSignature(w, req, channel, discovery.Config)
+}
+
+// authenticateTLS finds the msp whose tls roots issued the client certificate. Like signing identities, the client
+// certificate has to identify an admin or a peer of the msp.
+func authenticateTLS(req *http.Request, config ChannelConfig) (string, error) {
+	cert := req.TLS.PeerCertificates[0]
+	claimed := req.Header.Get(headerMSPID)
+	for mspID, msp := range config.MSPs {
+		if claimed != "" && claimed != mspID {
+			continue
+		}
+		if verifyCertificate(cert, req.TLS.PeerCertificates[1:], msp.TLSRootCerts, msp.TLSIntermediateCerts) != nil {
+			continue
+		}
+		if !msp.privileged(cert) {
+			return "", &AuthError{http.StatusForbidden, fmt.Sprintf("Client certificate %v is neither an admin nor a peer of %v", cert.Subject, mspID)}
+		}
+		return mspID, nil
+	}
+	return "", &AuthError{http.StatusForbidden, fmt.Sprintf("Client certificate %v was not issued by a member of the channel", cert.Subject)}
+}
+
+// authenticateSignature verifies the signature of the request against the roots of the claimed msp. The signing
+// identity has to be an admin or a peer of the msp and the nonce must not have been used before.
+func authenticateSignature(w http.ResponseWriter, req *http.Request, channel string, config ChannelConfig) (string, error) {
+	mspID := req.Header.Get(headerMSPID)
+	timestamp := req.Header.Get(headerTimestamp)
+	nonce := req.Header.Get(headerNonce)
+	if mspID == "" || timestamp == "" || nonce == "" || req.Header.Get(headerCertificate) == "" || req.Header.Get(headerSignature) == "" {
+		return "", &AuthError{http.StatusUnauthorized, "Missing signature of the requesting organization"}
+	}
+	if len(nonce) > maxNonceLength {
+		return "", &AuthError{http.StatusUnauthorized, "Invalid nonce"}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", &AuthError{http.StatusUnauthorized, fmt.Sprintf("Invalid timestamp %v", timestamp)}
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > signatureWindow || age < -signatureWindow {
+		return "", &AuthError{http.StatusUnauthorized, fmt.Sprintf("Signature timestamp %v is outside of %v", timestamp, signatureWindow)}
+	}
+
+	certPEM, err := base64.StdEncoding.DecodeString(req.Header.Get(headerCertificate))
+	if err != nil {
+		return "", &AuthError{http.StatusUnauthorized, "Invalid certificate encoding"}
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", &AuthError{http.StatusUnauthorized, "Invalid certificate"}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", &AuthError{http.StatusUnauthorized, fmt.Sprintf("Invalid certificate: %v", err)}
+	}
+	signature, err := base64.StdEncoding.DecodeString(req.Header.Get(headerSignature))
+	if err != nil {
+		return "", &AuthError{http.StatusUnauthorized, "Invalid signature encoding"}
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxSignedBodySize))
+	if err != nil {
+		return "", &AuthError{http.StatusBadRequest, fmt.Sprintf("Failed to read request: %v", err)}
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	message := signedMessage(req.Method, req.URL.RequestURI(), mspID, channel, timestamp, nonce, body)
+	if err := verifySignature(cert, message, signature); err != nil {
+		return "", &AuthError{http.StatusUnauthorized, err.Error()}
+	}
+
+	msp, ok := config.MSPs[mspID]
+	if !ok {
+		return "", &AuthError{http.StatusForbidden, fmt.Sprintf("%v is not a member of %v", mspID, channel)}
+	}
+	if err := verifyCertificate(cert, nil, msp.RootCerts, msp.IntermediateCerts); err != nil {
+		return "", &AuthError{http.StatusForbidden, fmt.Sprintf("Certificate %v was not issued by %v: %v", cert.Subject, mspID, err)}
+	}
+	if !msp.privileged(cert) {
+		return "", &AuthError{http.StatusForbidden, fmt.Sprintf("Certificate %v is neither an admin nor a peer of %v", cert.Subject, mspID)}
+	}
+
+	// the nonce is remembered as long as the timestamp is accepted.
+	if !nonces.Add(mspID+":"+nonce, time.Unix(seconds, 0).Add(signatureWindow)) {
+		return "", &AuthError{http.StatusUnauthorized, fmt.Sprintf("Replayed request of %v", mspID)}
+	}
+	return mspID, nil
+}
+
+// verifyCertificate verifies the certificate against the PEM encoded roots and intermediates of a msp. The chain
+// contains the intermediates presented along with the certificate.
+func verifyCertificate(cert *x509.Certificate, chain []*x509.Certificate, roots, mspIntermediates [][]byte) error {
+	pool := x509.NewCertPool()
+	for _, root := range roots {
+		pool.AppendCertsFromPEM(root)
+	}
+	intermediates := x509.NewCertPool()
+	for _, intermediate := range chain {
+		intermediates.AddCert(intermediate)
+	}
+	for _, intermediate := range mspIntermediates {
+		intermediates.AppendCertsFromPEM(intermediate)
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// verifySignature verifies the asn1 encoded ecdsa signature of the sha256 digest of the message.
+func verifySignature(cert *x509.Certificate, message, signature []byte) error {
+	key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("Unsupported public key of %v", cert.Subject)
+	}
+
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+		return fmt.Errorf("Invalid signature: %v", err)
+	}
+
+	digest := sha256.Sum256(message)
+	if !ecdsa.Verify(key, digest[:], sig.R, sig.S) {
+		return fmt.Errorf("Invalid signature of %v", cert.Subject)
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// testCA issues certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})}
+}
+
+// issue returns the PEM encoded certificate and key of a new identity with the organizational unit.
+func (ca *testCA) issue(t *testing.T, name, ou string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name, OrganizationalUnit: []string{ou}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// signedRequest builds a request signed by the identity of Org2MSP.
+func signedRequest(t *testing.T, cert, key []byte, body string) *http.Request {
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string][]byte{"keystore/priv_sk": key, "signcerts/cert.pem": cert} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previousMSPID, previousPath := os.Getenv("CORE_PEER_LOCALMSPID"), os.Getenv("CORE_PEER_MSPCONFIGPATH")
+	defer func() {
+		os.Setenv("CORE_PEER_LOCALMSPID", previousMSPID)
+		os.Setenv("CORE_PEER_MSPCONFIGPATH", previousPath)
+	}()
+	os.Setenv("CORE_PEER_LOCALMSPID", "Org2MSP")
+	os.Setenv("CORE_PEER_MSPCONFIGPATH", dir)
+
+	req := httptest.NewRequest("POST", "/mychannel/approve/mycc/1/mycc:abc", bytes.NewReader([]byte(body)))
+	if err := signRequest(req, "mychannel", []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return mux.SetURLVars(req, map[string]string{"channel": "mychannel"})
+}
+
+func TestAuthenticateSignature(t *testing.T) {
+	ca, outsider := newTestCA(t, "ca.org2.example.com"), newTestCA(t, "ca.evil.example.com")
+	adminCert, adminKey := ca.issue(t, "Admin@org2.example.com", "admin")
+	peerCert, peerKey := ca.issue(t, "peer0.org2.example.com", "peer")
+	clientCert, clientKey := ca.issue(t, "User1@org2.example.com", "client")
+	listedCert, listedKey := ca.issue(t, "Admin2@org2.example.com", "")
+	outsiderCert, outsiderKey := outsider.issue(t, "Admin@org2.example.com", "admin")
+
+	nodeOUs := &NodeOUs{
+		Enable:            true,
+		AdminOUIdentifier: &OUIdentifier{OrganizationalUnitIdentifier: "admin"},
+		PeerOUIdentifier:  &OUIdentifier{OrganizationalUnitIdentifier: "peer"},
+	}
+	withOUs := ChannelConfig{MSPs: map[string]MSPConfig{"Org2MSP": {RootCerts: [][]byte{ca.pem}, NodeOUs: nodeOUs}}}
+	withAdmins := ChannelConfig{MSPs: map[string]MSPConfig{"Org2MSP": {RootCerts: [][]byte{ca.pem}, Admins: [][]byte{listedCert}}}}
+
+	tests := []struct {
+		name    string
+		config  ChannelConfig
+		req     func() *http.Request
+		status  int
+		message string
+	}{
+		{name: "admin", config: withOUs, req: func() *http.Request { return signedRequest(t, adminCert, adminKey, "{}") }},
+		{name: "peer", config: withOUs, req: func() *http.Request { return signedRequest(t, peerCert, peerKey, "{}") }},
+		{name: "listed admin", config: withAdmins, req: func() *http.Request { return signedRequest(t, listedCert, listedKey, "{}") }},
+		{
+			name:    "client",
+			config:  withOUs,
+			req:     func() *http.Request { return signedRequest(t, clientCert, clientKey, "{}") },
+			status:  http.StatusForbidden,
+			message: "is neither an admin nor a peer of Org2MSP",
+		},
+		{
+			name:    "admin without node ous",
+			config:  withAdmins,
+			req:     func() *http.Request { return signedRequest(t, adminCert, adminKey, "{}") },
+			status:  http.StatusForbidden,
+			message: "is neither an admin nor a peer of Org2MSP",
+		},
+		{
+			name:    "other ca",
+			config:  withOUs,
+			req:     func() *http.Request { return signedRequest(t, outsiderCert, outsiderKey, "{}") },
+			status:  http.StatusForbidden,
+			message: "was not issued by Org2MSP",
+		},
+		{
+			name:   "tampered body",
+			config: withOUs,
+			req: func() *http.Request {
+				req := signedRequest(t, adminCert, adminKey, "{}")
+				req.Body = ioutil.NopCloser(bytes.NewReader([]byte(`{"definition": {}}`)))
+				return req
+			},
+			status:  http.StatusUnauthorized,
+			message: "Invalid signature",
+		},
+		{
+			name:   "missing nonce",
+			config: withOUs,
+			req: func() *http.Request {
+				req := signedRequest(t, adminCert, adminKey, "{}")
+				req.Header.Del(headerNonce)
+				return req
+			},
+			status:  http.StatusUnauthorized,
+			message: "Missing signature",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mspID, err := authenticateSignature(httptest.NewRecorder(), test.req(), "mychannel", test.config)
+			if test.status == 0 {
+				if err != nil || mspID != "Org2MSP" {
+					t.Fatalf("expected Org2MSP, got %v %v", mspID, err)
+				}
+				return
+			}
+			assertError(t, err, test.message)
+			if e, ok := err.(*AuthError); !ok || e.Status != test.status {
+				t.Errorf("expected status %v, got %v", test.status, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticateSignatureRejectsReplays(t *testing.T) {
+	ca := newTestCA(t, "ca.org2.example.com")
+	cert, key := ca.issue(t, "Admin@org2.example.com", "admin")
+	config := ChannelConfig{MSPs: map[string]MSPConfig{"Org2MSP": {RootCerts: [][]byte{ca.pem}, Admins: [][]byte{cert}}}}
+
+	req := signedRequest(t, cert, key, "{}")
+	replay := req.Clone(req.Context())
+	replay.Body = ioutil.NopCloser(bytes.NewReader([]byte("{}")))
+
+	if _, err := authenticateSignature(httptest.NewRecorder(), req, "mychannel", config); err != nil {
+		t.Fatal(err)
+	}
+	_, err := authenticateSignature(httptest.NewRecorder(), replay, "mychannel", config)
+	assertError(t, err, "Replayed request of Org2MSP")
+}
+
+func TestAuthenticateFallsBackToSignature(t *testing.T) {
+	ca := newTestCA(t, "ca.org2.example.com")
+	cert, key := ca.issue(t, "Admin@org2.example.com", "admin")
+	proxyCert, _ := newTestCA(t, "proxy.example.com").issue(t, "proxy.example.com", "")
+	config, err := json.Marshal(ChannelConfig{MSPs: map[string]MSPConfig{
+		"Org2MSP": {RootCerts: [][]byte{ca.pem}, TLSRootCerts: [][]byte{ca.pem}, Admins: [][]byte{cert}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := (&FakeRunner{}).
+		On("[]", "", nil, "discover", "peers").
+		On(string(config), "", nil, "discover", "config")
+	_, restore := newTestLifecycle(t, fake, nil)
+	defer restore()
+	previous := runner
+	defer func() { runner = previous }()
+	runner = fake
+
+	block, _ := pem.Decode(proxyCert)
+	proxy, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsState := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{proxy}}
+
+	req := signedRequest(t, cert, key, "{}")
+	req.TLS = tlsState
+	if mspID, err := authenticate(httptest.NewRecorder(), req); err != nil || mspID != "Org2MSP" {
+		t.Fatalf("expected Org2MSP, got %v %v", mspID, err)
+	}
+
+	unsigned := httptest.NewRequest("POST", "/mychannel/approve/mycc/1/mycc:abc", nil)
+	unsigned = mux.SetURLVars(unsigned, map[string]string{"channel": "mychannel"})
+	unsigned.TLS = tlsState
+	_, err = authenticate(httptest.NewRecorder(), unsigned)
+	assertError(t, err, "was not issued by a member of the channel")
+}
+
+func TestAuthenticateTLS(t *testing.T) {
+	ca := newTestCA(t, "tlsca.org1.example.com")
+	nodeOUs := &NodeOUs{
+		Enable:            true,
+		AdminOUIdentifier: &OUIdentifier{OrganizationalUnitIdentifier: "admin"},
+		PeerOUIdentifier:  &OUIdentifier{OrganizationalUnitIdentifier: "peer"},
+	}
+	config := ChannelConfig{MSPs: map[string]MSPConfig{"Org1MSP": {TLSRootCerts: [][]byte{ca.pem}, NodeOUs: nodeOUs}}}
+
+	tests := []struct {
+		name    string
+		ou      string
+		message string
+	}{
+		{name: "peer", ou: "peer"},
+		{name: "admin", ou: "admin"},
+		{name: "client", ou: "client", message: "is neither an admin nor a peer of Org1MSP"},
+		{name: "without organizational unit", message: "is neither an admin nor a peer of Org1MSP"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			certPEM, _ := ca.issue(t, "tls.org1.example.com", test.ou)
+			block, _ := pem.Decode(certPEM)
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("POST", "/install/mycc", nil)
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+			mspID, err := authenticateTLS(req, config)
+			assertError(t, err, test.message)
+			if err == nil && mspID != "Org1MSP" {
+				t.Errorf("expected Org1MSP, got %v", mspID)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// Backend performs the chaincode lifecycle operations against the peers and the orderer.
+type Backend interface {
+	// Install installs the chaincode package on the target peer and returns its package id.
+	Install(ctx context.Context, target Target, pkg []byte) (string, error)
+	// QueryInstalled lists the chaincodes installed on the target peer.
+	QueryInstalled(ctx context.Context, target Target) ([]InstalledChaincode, error)
+	// ApproveForMyOrg approves the chaincode definition for the local msp.
+	ApproveForMyOrg(ctx context.Context, channel, name string, sequence int, packageID string, definition ChaincodeDefinition) error
+	// CheckCommitReadiness returns the approval status of the chaincode definition per msp.
+	CheckCommitReadiness(ctx context.Context, channel, name string, sequence int, definition ChaincodeDefinition) (map[string]bool, error)
+	// Commit commits the chaincode definition, collecting endorsements from the given targets, and waits until the
+	// transaction has been validated by the targets. Returns a ValidationError if the transaction was invalidated.
+	Commit(ctx context.Context, channel, name string, sequence int, definition ChaincodeDefinition, targets []Target) ([]TxStatus, error)
+	// Init invokes the init function of the committed chaincode, collecting endorsements from the given targets, and
+	// waits until the transaction has been validated by the targets.
+	Init(ctx context.Context, channel, name, function string, args []string, targets []Target) ([]TxStatus, error)
+	// QueryCommitted returns the committed chaincode definition.
+	QueryCommitted(ctx context.Context, channel, name string) (QueryCommitted, error)
+	// GetInstalledPackage returns the chaincode package installed on the target peer.
+	GetInstalledPackage(ctx context.Context, target Target, packageID string) ([]byte, error)
+	// GetConfigBlock returns the latest config block of the channel.
+	GetConfigBlock(ctx context.Context, channel string) (*common.Block, error)
+}
+
+// Target describes a peer and the identity used to talk to it.
+type Target struct {
+	Address       string
+	TLSRootCert   string // path to the peers tls root cert
+	MSPConfigPath string // path to the msp of the identity, defaults to CORE_PEER_MSPCONFIGPATH
+}
+
+// TxStatus represents the validation result of a transaction on a peer. The block number is only known if the
+// transaction was observed by the native backend.
+type TxStatus struct {
+	Peer  string `json:"peer"`
+	TxID  string `json:"txid,omitempty"`
+	Block uint64 `json:"block,omitempty"`
+	Code  string `json:"code"`
+}
+
+// ValidationError is returned if a transaction has been invalidated, e.g. by a MVCC_READ_CONFLICT.
+type ValidationError struct {
+	TxID string
+	Peer string
+	Code string
+}
+
+func (e *ValidationError) Error() string {
+	message := "Transaction"
+	if e.TxID != "" {
+		message += " " + e.TxID
+	}
+	message += " invalidated with status " + e.Code
+	if e.Peer != "" {
+		message += " at " + e.Peer
+	}
+	return message
+}
+
+// InstalledChaincode represents a chaincode package installed on a peer.
+type InstalledChaincode struct {
+	PackageID  string                         `json:"package_id"`
+	Label      string                         `json:"label"`
+	References map[string]ChaincodeReferences `json:"references"`
+}
+
+// ChaincodeReferences lists the chaincodes referencing an installed package on a channel.
+type ChaincodeReferences struct {
+	Chaincodes []ChaincodeReference `json:"chaincodes"`
+}
+
+// ChaincodeReference represents a chaincode definition referencing an installed package.
+type ChaincodeReference struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// localTarget returns the target of the local peer.
+func localTarget() Target {
+	return Target{
+		Address:       os.Getenv("CORE_PEER_ADDRESS"),
+		TLSRootCert:   os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE"),
+		MSPConfigPath: os.Getenv("CORE_PEER_MSPCONFIGPATH"),
+	}
+}
+
+// NewBackend returns the backend configured by LIFECYCLE_BACKEND. Defaults to the peer cli.
+func NewBackend(runner Runner) (Backend, error) {
+	switch backend := os.Getenv("LIFECYCLE_BACKEND"); backend {
+	case "", "cli":
+		return &CLIBackend{Runner: runner}, nil
+	case "native":
+		return &NativeBackend{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown lifecycle backend %v", backend)
+	}
+}
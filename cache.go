@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultDiscoveryCacheTTL defines how long discovery results are reused if DISCOVERY_CACHE_TTL is not set.
+const defaultDiscoveryCacheTTL = time.Minute
+
+// discoveries caches the discovery results per channel.
+var discoveries = NewDiscoveryCache(defaultDiscoveryCacheTTL)
+
+// DiscoveryCache is an in-memory cache of discovery results per channel. Root certs are written to files once and
+// reused by all discoveries.
+type DiscoveryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*discoveryEntry
+	rootCAs map[string]string
+	now     func() time.Time // the clock of the cache, replaced in tests
+}
+
+type discoveryEntry struct {
+	mu        sync.Mutex
+	discovery Discovery
+	expires   time.Time
+}
+
+// NewDiscoveryCache builds a new discovery cache. A ttl of 0 disables caching.
+func NewDiscoveryCache(ttl time.Duration) *DiscoveryCache {
+	return &DiscoveryCache{ttl: ttl, entries: make(map[string]*discoveryEntry), rootCAs: make(map[string]string), now: time.Now}
+}
+
+// discoveryCacheTTL returns the ttl configured by DISCOVERY_CACHE_TTL.
+func discoveryCacheTTL() (time.Duration, error) {
+	value := os.Getenv("DISCOVERY_CACHE_TTL")
+	if value == "" {
+		return defaultDiscoveryCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid discovery cache ttl %v: %v", value, err)
+	}
+	return ttl, nil
+}
+
+func (c *DiscoveryCache) entry(channel string) *discoveryEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[channel]; !ok {
+		c.entries[channel] = &discoveryEntry{}
+	}
+	return c.entries[channel]
+}
+
+// Load returns the cached discovery of the channel or calls fetch if it has expired. Concurrent loads of the same
+// channel wait for a single fetch.
+func (c *DiscoveryCache) Load(channel string, fetch func() (Discovery, error)) (Discovery, error) {
+	entry := c.entry(channel)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if c.now().Before(entry.expires) {
+		return entry.discovery, nil
+	}
+
+	discovery, err := fetch()
+	if err != nil {
+		return discovery, err
+	}
+	entry.discovery = discovery
+	entry.expires = c.now().Add(c.ttl)
+	return discovery, nil
+}
+
+// Invalidate drops the cached discovery of the channel.
+func (c *DiscoveryCache) Invalidate(channel string) {
+	entry := c.entry(channel)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.discovery = Discovery{}
+	entry.expires = time.Time{}
+}
+
+// rootCAFile returns the path of a file containing the root cert. Each root cert is written only once.
+func (c *DiscoveryCache) rootCAFile(mspID string, rootCA []byte) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := fmt.Sprintf("%v:%x", mspID, sha256.Sum256(rootCA))
+	if path, ok := c.rootCAs[key]; ok {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	path, err := workdir.Shared(fmt.Sprintf("%v-*.pem", mspID), rootCA)
+	if err != nil {
+		return "", err
+	}
+
+	c.rootCAs[key] = path
+	return path, nil
+}
+
+// RefreshTopology drops the cached discovery of the channel and returns the newly discovered topology.
+func RefreshTopology(w http.ResponseWriter, req *http.Request) {
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
+	discoveries.Invalidate(lifecycle.Channel)
+	GetTopology(w, req)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeClock is a clock which only moves when advanced.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestDiscoveryCacheExpires(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		advance time.Duration
+		fetches int
+	}{
+		{name: "cached", ttl: time.Minute, advance: 59 * time.Second, fetches: 1},
+		{name: "expired", ttl: time.Minute, advance: time.Minute, fetches: 2},
+		{name: "disabled", ttl: 0, fetches: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)}
+			cache := NewDiscoveryCache(test.ttl)
+			cache.now = clock.Now
+
+			fetches := 0
+			fetch := func() (Discovery, error) {
+				fetches++
+				return Discovery{Peers: []DiscoveredPeer{{MSPID: "Org1MSP", LedgerHeight: uint64(fetches)}}}, nil
+			}
+
+			if _, err := cache.Load("mychannel", fetch); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(test.advance)
+			discovery, err := cache.Load("mychannel", fetch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fetches != test.fetches {
+				t.Errorf("expected %v fetches, got %v", test.fetches, fetches)
+			}
+			if discovery.Peers[0].LedgerHeight != uint64(fetches) {
+				t.Errorf("expected the latest discovery, got %+v", discovery)
+			}
+		})
+	}
+}
+
+func TestDiscoveryCacheKeepsChannelsApart(t *testing.T) {
+	cache := NewDiscoveryCache(time.Minute)
+	channels := []string{}
+	fetch := func(channel string) func() (Discovery, error) {
+		return func() (Discovery, error) {
+			channels = append(channels, channel)
+			return Discovery{}, nil
+		}
+	}
+
+	for _, channel := range []string{"mychannel", "otherchannel", "mychannel"} {
+		if _, err := cache.Load(channel, fetch(channel)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache.Invalidate("otherchannel")
+	for _, channel := range []string{"mychannel", "otherchannel"} {
+		if _, err := cache.Load(channel, fetch(channel)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fmt.Sprint(channels) != "[mychannel otherchannel otherchannel]" {
+		t.Errorf("unexpected fetches %v", channels)
+	}
+}
+
+func TestDiscoveryCacheDoesNotCacheErrors(t *testing.T) {
+	cache := NewDiscoveryCache(time.Minute)
+	fetches := 0
+	fetch := func() (Discovery, error) {
+		fetches++
+		if fetches == 1 {
+			return Discovery{}, fmt.Errorf("Failed to discover peers")
+		}
+		return Discovery{}, nil
+	}
+
+	_, err := cache.Load("mychannel", fetch)
+	assertError(t, err, "Failed to discover peers")
+	_, err = cache.Load("mychannel", fetch)
+	assertError(t, err, "")
+	if fetches != 2 {
+		t.Errorf("expected the failed discovery to be retried, got %v fetches", fetches)
+	}
+}
+
+func TestDiscoveryCacheTTL(t *testing.T) {
+	tests := []struct {
+		value   string
+		ttl     time.Duration
+		message string
+	}{
+		{value: "", ttl: defaultDiscoveryCacheTTL},
+		{value: "30s", ttl: 30 * time.Second},
+		{value: "0", ttl: 0},
+		{value: "soon", message: "Invalid discovery cache ttl soon"},
+	}
+
+	previous := os.Getenv("DISCOVERY_CACHE_TTL")
+	defer os.Setenv("DISCOVERY_CACHE_TTL", previous)
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q", test.value), func(t *testing.T) {
+			os.Setenv("DISCOVERY_CACHE_TTL", test.value)
+			ttl, err := discoveryCacheTTL()
+			assertError(t, err, test.message)
+			if ttl != test.ttl {
+				t.Errorf("expected ttl %v, got %v", test.ttl, ttl)
+			}
+		})
+	}
+}
+
+func TestCommitDropsTheDiscoveryCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		fetches int
+	}{
+		{name: "committed", fetches: 2},
+		{name: "failed", err: fmt.Errorf("exit status 1"), fetches: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := (&FakeRunner{}).On("", recorded(t, "commit_valid.log"), test.err, "peer", "lifecycle", "chaincode", "commit")
+			lifecycle, restore := newTestLifecycle(t, fake, map[string]string{"sequence": "1"})
+			defer restore()
+			discoveries = NewDiscoveryCache(time.Hour)
+			lifecycle.Nodes = []Node{{Name: "peer-0", MSPID: "Org1MSP", Host: "org1.example.com", RootCA: "/certs/org1.pem"}}
+			lifecycle.job = NewJobs().Add(lifecycle.Channel, lifecycle.Chaincode)
+
+			fetches := 0
+			fetch := func() (Discovery, error) {
+				fetches++
+				return Discovery{}, nil
+			}
+			if _, err := discoveries.Load(lifecycle.Channel, fetch); err != nil {
+				t.Fatal(err)
+			}
+			if err := lifecycle.Commit(); (err != nil) != (test.err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := discoveries.Load(lifecycle.Channel, fetch); err != nil {
+				t.Fatal(err)
+			}
+
+			if fetches != test.fetches {
+				t.Errorf("expected %v fetches, got %v", test.fetches, fetches)
+			}
+		})
+	}
+}
+
+func TestRefreshTopology(t *testing.T) {
+	fake := (&FakeRunner{}).
+		On(recorded(t, "discover_peers.json"), "", nil, "discover", "peers").
+		On(recorded(t, "discover_config.json"), "", nil, "discover", "config")
+	_, restore := newTestLifecycle(t, fake, nil)
+	defer restore()
+	previousRunner, previousBackend := runner, backend
+	defer func() { runner, backend = previousRunner, previousBackend }()
+	runner, backend = fake, &CLIBackend{Runner: fake}
+	discoveries = NewDiscoveryCache(time.Hour)
+
+	serve := func(handler http.HandlerFunc) {
+		req := httptest.NewRequest("GET", "/mychannel/topology", nil)
+		req = mux.SetURLVars(req, map[string]string{"channel": "mychannel"})
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %v: %v", w.Code, w.Body.String())
+		}
+	}
+
+	serve(GetTopology)
+	serve(GetTopology)
+	if discovered := len(fake.Commands("discover", "peers")); discovered != 1 {
+		t.Fatalf("expected the topology to be served from the cache, got %v discoveries", discovered)
+	}
+
+	serve(RefreshTopology)
+	if discovered := len(fake.Commands("discover", "peers")); discovered != 2 {
+		t.Errorf("expected the refresh to discover the network again, got %v discoveries", discovered)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// CLIBackend performs the lifecycle operations using the peer cli.
+type CLIBackend struct {
+	Runner Runner
+}
+
+// Install installs the chaincode package using the msp of the target.
+func (c *CLIBackend) Install(ctx context.Context, target Target, pkg []byte) (string, error) {
+	operation, err := workdir.Operation("install")
+	if err != nil {
+		return "", err
+	}
+	defer operation.Close()
+
+	// the cli expects the package as file.
+	file, err := operation.File("package-*.tgz", pkg)
+	if err != nil {
+		return "", err
+	}
+
+	var env []string
+	if target.MSPConfigPath != "" {
+		env = append(env, fmt.Sprintf("CORE_PEER_MSPCONFIGPATH=%v", target.MSPConfigPath))
+	}
+	command := []string{
+		"peer", "lifecycle", "chaincode", "install", file,
+		"--peerAddresses", target.Address,
+		"--tlsRootCertFiles", target.TLSRootCert,
+	}
+
+	response, err := c.Runner.Run(ctx, env, command...)
+	if err != nil {
+		return "", err
+	}
+
+	// the package id is only logged by the cli, an empty id is verified by the caller. The install response logged
+	// before contains the package id in its raw payload, hence the identifier line is matched.
+	identifiers, err := response.findAllInLogs(`Chaincode code package identifier: ([^\s:]+:[0-9a-f]{64})`)
+	if err != nil || len(identifiers) == 0 {
+		return "", err
+	}
+	return identifiers[0][1], nil
+}
+
+// QueryInstalled lists the installed chaincodes on the target.
+func (c *CLIBackend) QueryInstalled(ctx context.Context, target Target) ([]InstalledChaincode, error) {
+	command := []string{
+		"peer", "lifecycle", "chaincode", "queryinstalled",
+		"--peerAddresses", target.Address,
+		"--tlsRootCertFiles", target.TLSRootCert,
+		"-O", "json",
+	}
+
+	response, err := c.Runner.Run(ctx, nil, command...)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed struct {
+		InstalledChaincodes []InstalledChaincode `json:"installed_chaincodes"`
+	}
+	if err := json.Unmarshal(response.Output.Bytes(), &installed); err != nil {
+		return nil, err
+	}
+	return installed.InstalledChaincodes, nil
+}
+
+// ApproveForMyOrg approves the chaincode definition for the local msp.
+func (c *CLIBackend) ApproveForMyOrg(ctx context.Context, channel, name string, sequence int, packageID string, definition ChaincodeDefinition) error {
+	operation, err := workdir.Operation("approve")
+	if err != nil {
+		return err
+	}
+	defer operation.Close()
+
+	args, err := definitionArgs(operation, definition)
+	if err != nil {
+		return err
+	}
+
+	command := []string{
+		"peer", "lifecycle", "chaincode", "approveformyorg",
+		"--channelID", channel,
+		"--name", name,
+		"--package-id", packageID,
+		"--sequence", strconv.Itoa(sequence),
+		"-o", os.Getenv("ORDERER_ADDRESS"),
+		"--tls",
+		"--cafile", os.Getenv("ORDERER_CA"),
+	}
+
+	_, err = c.Runner.Run(ctx, nil, append(command, args...)...)
+	return err
+}
+
+// CheckCommitReadiness returns the approvals of the chaincode definition.
+func (c *CLIBackend) CheckCommitReadiness(ctx context.Context, channel, name string, sequence int, definition ChaincodeDefinition) (map[string]bool, error) {
+	operation, err := workdir.Operation("checkcommitreadiness")
+	if err != nil {
+		return nil, err
+	}
+	defer operation.Close()
+
+	args, err := definitionArgs(operation, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	command := []string{
+		"peer", "lifecycle", "chaincode", "checkcommitreadiness",
+		"--channelID", channel,
+		"--name", name,
+		"--sequence", strconv.Itoa(sequence),
+		"-o", os.Getenv("ORDERER_ADDRESS"),
+		"--tls",
+		"--cafile", os.Getenv("ORDERER_CA"),
+		"-O", "json",
+	}
+
+	response, err := c.Runner.Run(ctx, nil, append(command, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var readiness struct {
+		Approvals map[string]bool `json:"approvals"`
+	}
+	if err := json.Unmarshal(response.Output.Bytes(), &readiness); err != nil {
+		return nil, err
+	}
+	return readiness.Approvals, nil
+}
+
+// Commit commits the chaincode definition using the given targets as endorsers and waits for the commit event of
+// every target. The cli does not report the block number of the transaction.
+func (c *CLIBackend) Commit(ctx context.Context, channel, name string, sequence int, definition ChaincodeDefinition, targets []Target) ([]TxStatus, error) {
+	operation, err := workdir.Operation("commit")
+	if err != nil {
+		return nil, err
+	}
+	defer operation.Close()
+
+	args, err := definitionArgs(operation, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	command := []string{
+		"peer", "lifecycle", "chaincode", "commit",
+		"--channelID", channel,
+		"--name", name,
+		"--sequence", strconv.Itoa(sequence),
+		"-o", os.Getenv("ORDERER_ADDRESS"),
+		"--tls",
+		"--cafile", os.Getenv("ORDERER_CA"),
+		"--waitForEvent",
+	}
+	command = append(command, args...)
+
+	for _, target := range targets {
+		command = append(command, "--peerAddresses", target.Address)
+		command = append(command, "--tlsRootCertFiles", target.TLSRootCert)
+	}
+
+	return txStatuses(c.Runner.Run(ctx, nil, command...))
+}
+
+// Init invokes the init function of the committed chaincode on the targets and waits for the commit event of every
+// target.
+func (c *CLIBackend) Init(ctx context.Context, channel, name, function string, args []string, targets []Target) ([]TxStatus, error) {
+	input, err := json.Marshal(struct {
+		Args []string `json:"Args"`
+	}{append([]string{function}, args...)})
+	if err != nil {
+		return nil, err
+	}
+
+	command := []string{
+		"peer", "chaincode", "invoke",
+		"--channelID", channel,
+		"--name", name,
+		"--isInit",
+		"--ctor", string(input),
+		"-o", os.Getenv("ORDERER_ADDRESS"),
+		"--tls",
+		"--cafile", os.Getenv("ORDERER_CA"),
+		"--waitForEvent",
+	}
+
+	for _, target := range targets {
+		command = append(command, "--peerAddresses", target.Address)
+		command = append(command, "--tlsRootCertFiles", target.TLSRootCert)
+	}
+
+	return txStatuses(c.Runner.Run(ctx, nil, command...))
+}
+
+// txStatuses parses the commit events logged by the cli when waiting for a transaction.
+func txStatuses(response Response, err error) ([]TxStatus, error) {
+	if err != nil {
+		invalidated, _ := response.findAllInLogs(`transaction invalidated with status \((\w+)\)`)
+		if len(invalidated) > 0 {
+			return nil, &ValidationError{Code: invalidated[0][1]}
+		}
+		return nil, err
+	}
+
+	committed, err := response.findAllInLogs(`txid \[([0-9a-f]+)\] committed with status \((\w+)\) at (\S+)`)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []TxStatus
+	for _, match := range committed {
+		statuses = append(statuses, TxStatus{Peer: match[3], TxID: match[1], Code: match[2]})
+	}
+	return statuses, nil
+}
+
+// QueryCommitted returns the committed chaincode definition.
+func (c *CLIBackend) QueryCommitted(ctx context.Context, channel, name string) (QueryCommitted, error) {
+	command := []string{
+		"peer", "lifecycle", "chaincode", "querycommitted",
+		"--channelID", channel,
+		"--name", name,
+		"-o", os.Getenv("ORDERER_ADDRESS"),
+		"--tls",
+		"--cafile", os.Getenv("ORDERER_CA"),
+		"--peerAddresses", os.Getenv("CORE_PEER_ADDRESS"),
+		"--tlsRootCertFiles", os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE"),
+		"-O", "json",
+	}
+
+	var committed QueryCommitted
+	response, err := c.Runner.Run(ctx, nil, command...)
+	if err != nil {
+		if notDefined(name, response.Logs.String()) {
+			return committed, &NotDefinedError{Chaincode: name}
+		}
+		return committed, err
+	}
+
+	if err := json.Unmarshal(response.Output.Bytes(), &committed); err != nil {
+		return committed, fmt.Errorf("Invalid committed definition of %v: %v", name, err)
+	}
+	return committed, nil
+}
+
+// GetInstalledPackage downloads the installed chaincode package from the target.
+func (c *CLIBackend) GetInstalledPackage(ctx context.Context, target Target, packageID string) ([]byte, error) {
+	operation, err := workdir.Operation("getinstalledpackage")
+	if err != nil {
+		return nil, err
+	}
+	defer operation.Close()
+
+	command := []string{
+		"peer", "lifecycle", "chaincode", "getinstalledpackage",
+		"--package-id", packageID,
+		"--output-directory", operation.Dir,
+		"--peerAddresses", target.Address,
+		"--tlsRootCertFiles", target.TLSRootCert,
+	}
+
+	if _, err := c.Runner.Run(ctx, nil, command...); err != nil {
+		return nil, err
+	}
+
+	// the cli names the file after the package id.
+	files, err := ioutil.ReadDir(operation.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("Missing package %v", packageID)
+	}
+	return ioutil.ReadFile(filepath.Join(operation.Dir, files[0].Name()))
+}
+
+// GetConfigBlock fetches the latest config block of the channel from the orderer.
+func (c *CLIBackend) GetConfigBlock(ctx context.Context, channel string) (*common.Block, error) {
+	operation, err := workdir.Operation("fetchconfig")
+	if err != nil {
+		return nil, err
+	}
+	defer operation.Close()
+
+	path := filepath.Join(operation.Dir, "config.block")
+	command := []string{
+		"peer", "channel", "fetch", "config", path,
+		"--channelID", channel,
+		"-o", os.Getenv("ORDERER_ADDRESS"),
+		"--tls",
+		"--cafile", os.Getenv("ORDERER_CA"),
+	}
+
+	if _, err := c.Runner.Run(ctx, nil, command...); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var block common.Block
+	if err := proto.Unmarshal(data, &block); err != nil {
+		return nil, fmt.Errorf("Invalid config block of %v: %v", channel, err)
+	}
+	return &block, nil
+}
+
+// definitionArgs returns the cli flags of the chaincode definition. The collections are written to a file of the
+// operation.
+func definitionArgs(operation *Operation, definition ChaincodeDefinition) ([]string, error) {
+	args := []string{"--version", definition.Version}
+	if definition.InitRequired {
+		args = append(args, "--init-required")
+	}
+	if definition.SignaturePolicy != "" {
+		args = append(args, "--signature-policy", definition.SignaturePolicy)
+	}
+	if definition.ChannelConfigPolicy != "" {
+		args = append(args, "--channel-config-policy", definition.ChannelConfigPolicy)
+	}
+	if definition.EndorsementPlugin != "" {
+		args = append(args, "--endorsement-plugin", definition.EndorsementPlugin)
+	}
+	if definition.ValidationPlugin != "" {
+		args = append(args, "--validation-plugin", definition.ValidationPlugin)
+	}
+	if len(definition.Collections) == 0 {
+		return args, nil
+	}
+
+	collections, err := json.Marshal(definition.Collections)
+	if err != nil {
+		return nil, err
+	}
+	file, err := operation.File("collections-*.json", collections)
+	if err != nil {
+		return nil, err
+	}
+	return append(args, "--collections-config", file), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// collectionMSPs returns the msp ids referenced by the member and endorsement policies of the collection.
+func (c Collection) collectionMSPs() ([]string, error) {
+	mspIDs, err := PolicyMSPs(c.Policy)
+	if err != nil {
+		return nil, err
+	}
+	if c.EndorsementPolicy != nil && c.EndorsementPolicy.SignaturePolicy != "" {
+		endorsers, err := PolicyMSPs(c.EndorsementPolicy.SignaturePolicy)
+		if err != nil {
+			return nil, err
+		}
+		mspIDs = append(mspIDs, endorsers...)
+	}
+	return mspIDs, nil
+}
+
+// collectionsVerifyTimeout defines how long the approvals of the collection config are awaited on the local peer.
+const collectionsVerifyTimeout = 30 * time.Second
+
+// ValidateCollections checks that the collections only reference msps of the discovered nodes.
+func (l *Lifecycle) ValidateCollections() error {
+	members := make(map[string]bool)
+	for _, node := range l.Nodes {
+		members[node.MSPID] = true
+	}
+	for _, collection := range l.Definition.Collections {
+		mspIDs, err := collection.collectionMSPs()
+		if err != nil {
+			return fmt.Errorf("Invalid collection %v: %v", collection.Name, err)
+		}
+		for _, mspID := range mspIDs {
+			if !members[mspID] {
+				return fmt.Errorf("Collection %v references %v which is not a member of %v", collection.Name, mspID, l.Channel)
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyCollections checks that every organization which approved the definition during the deployment approved the
+// same collection config. The commit readiness of the definition including its collections is queried until it lists
+// the approvals of these organizations, the local peer may not have received their approvals yet. Organizations still
+// missing have approved a different collection config.
+func (l *Lifecycle) VerifyCollections() error {
+	ctx, cancel := context.WithTimeout(l.context(), collectionsVerifyTimeout)
+	defer cancel()
+
+	for {
+		var mismatches []string
+		approvals, err := l.Backend.CheckCommitReadiness(ctx, l.Channel, l.Chaincode, l.Sequence, l.Definition)
+		if err == nil {
+			for _, mspID := range l.approvers {
+				if !approvals[mspID] {
+					mismatches = append(mismatches, mspID)
+				}
+			}
+			if len(mismatches) == 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("Failed to verify the approved collections: %v", err)
+			}
+			sort.Strings(mismatches)
+			return fmt.Errorf("%v approved a different collection config than %v", strings.Join(mismatches, ", "), l.MSPID)
+		case <-time.After(commitReadinessInterval):
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVerifyCollections(t *testing.T) {
+	tests := []struct {
+		name      string
+		readiness string
+		approvers []string
+		message   string
+	}{
+		{
+			name:      "same collections",
+			readiness: `{"approvals": {"Org1MSP": true, "Org2MSP": true, "Org3MSP": false}}`,
+			approvers: []string{"Org1MSP", "Org2MSP"},
+		},
+		{
+			name:      "different collections",
+			readiness: `{"approvals": {"Org1MSP": true, "Org2MSP": false, "Org3MSP": false}}`,
+			approvers: []string{"Org1MSP", "Org3MSP", "Org2MSP"},
+			message:   "Org2MSP, Org3MSP approved a different collection config than Org1MSP",
+		},
+		{
+			name:      "empty readiness",
+			readiness: `{}`,
+			approvers: []string{"Org1MSP"},
+			message:   "Org1MSP approved a different collection config",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := (&FakeRunner{}).On(test.readiness, "", nil, "peer", "lifecycle", "chaincode", "checkcommitreadiness")
+			lifecycle, restore := newTestLifecycle(t, fake, nil)
+			defer restore()
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			lifecycle.ctx = ctx
+			lifecycle.approvers = test.approvers
+			lifecycle.Definition.Collections = []Collection{{Name: "private", Policy: "OR('Org1MSP.member','Org2MSP.member')"}}
+
+			assertError(t, lifecycle.VerifyCollections(), test.message)
+
+			checks := fake.Commands("peer", "lifecycle", "chaincode", "checkcommitreadiness")
+			if len(checks) == 0 {
+				t.Fatal("expected a readiness check")
+			}
+			if collections := checks[0][len(checks[0])-2]; collections != "--collections-config" {
+				t.Errorf("expected the readiness of the collections, got %v", checks[0])
+			}
+		})
+	}
+}
@@ -1,35 +1,41 @@
 package main
 
-import (
-	"fmt"
-	"os"
-	"strings"
-)
-
 // Commit commits the chaincode to the network, using the nodes discovered by the discovery services.
 func (l *Lifecycle) Commit() error {
-	command := []string{
-		"peer lifecycle chaincode commit",
-		fmt.Sprintf("--channelID %v", l.Channel),
-		fmt.Sprintf("--name %v", l.Chaincode),
-		fmt.Sprintf("--version %v", "1.0"),
-		fmt.Sprintf("--sequence %v", l.Sequence),
-		fmt.Sprintf("-o %v", os.Getenv("ORDERER_ADDRESS")),
-		fmt.Sprintf("--tls %v", "true"),
-		fmt.Sprintf("--cafile %v", os.Getenv("ORDERER_CA")),
+	targets, err := l.endorsingTargets()
+	if err != nil {
+		return err
 	}
-	// --init-required
 
-	for _, node := range l.Nodes {
-		if node.Name != "peer-0" {
-			continue
+	// committing chaincode installation
+	statuses, err := l.Backend.Commit(l.context(), l.Channel, l.Chaincode, l.Sequence, l.Definition, targets)
+	l.job.setCommit(statuses)
+	for _, status := range statuses {
+		if status.Block > 0 {
+			l.job.Infof("%v validated the commit in block %v with status %v", status.Peer, status.Block, status.Code)
+		} else {
+			l.job.Infof("%v validated the commit with status %v", status.Peer, status.Code)
 		}
-		fmt.Println(fmt.Sprintf("--peerAddresses peer.%v:%v", node.Host, "7051"))
-		command = append(command, fmt.Sprintf("--peerAddresses peer.%v:%v", node.Host, "7051"))
-		command = append(command, fmt.Sprintf("--tlsRootCertFiles %v", node.RootCA))
+	}
+	if err != nil {
+		return err
 	}
 
-	// committing chaincode installation
-	_, err := l.execute(strings.Join(command, " "))
-	return err
+	// the chaincodes of the peers have changed.
+	discoveries.Invalidate(l.Channel)
+	return nil
+}
+
+// endorsingTargets returns one peer per discovered organization, which satisfies any endorsement policy.
+func (l *Lifecycle) endorsingTargets() ([]Target, error) {
+	var targets []Target
+	for _, node := range l.leaders() {
+		n := naming.For(node.MSPID)
+		address, err := n.address(n.EndorserAddress, node)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, Target{Address: address, TLSRootCert: node.RootCA})
+	}
+	return targets, nil
 }
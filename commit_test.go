@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCommit(t *testing.T) {
+	tests := []struct {
+		name     string
+		logs     string
+		err      error
+		statuses []TxStatus
+		message  string
+	}{
+		{
+			name: "validated by all endorsers",
+			logs: recorded(t, "commit_valid.log"),
+			statuses: []TxStatus{
+				{Peer: "peer.org1.example.com:7051", TxID: "5c1a0e9c2b7d4f0a8e6b3d2c1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e", Code: "VALID"},
+				{Peer: "peer.org2.example.com:7051", TxID: "5c1a0e9c2b7d4f0a8e6b3d2c1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e", Code: "VALID"},
+			},
+		},
+		{
+			name:    "invalidated",
+			logs:    recorded(t, "commit_invalidated.log"),
+			err:     fmt.Errorf("exit status 1"),
+			message: "Transaction invalidated with status ENDORSEMENT_POLICY_FAILURE",
+		},
+		{
+			name:    "failed",
+			err:     fmt.Errorf("exit status 1"),
+			message: "exit status 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := (&FakeRunner{}).On("", test.logs, test.err, "peer", "lifecycle", "chaincode", "commit")
+			lifecycle, restore := newTestLifecycle(t, fake, map[string]string{"sequence": "3"})
+			defer restore()
+			lifecycle.Nodes = []Node{
+				{Name: "peer-0", MSPID: "Org1MSP", Host: "org1.example.com", RootCA: "/certs/org1.pem"},
+				{Name: "peer-1", MSPID: "Org2MSP", Host: "org2.example.com", RootCA: "/certs/org2.pem"},
+				{Name: "peer-0", MSPID: "Org2MSP", Host: "org2.example.com", RootCA: "/certs/org2.pem"},
+			}
+			lifecycle.Definition.InitRequired = true
+			lifecycle.job = NewJobs().Add(lifecycle.Channel, lifecycle.Chaincode)
+
+			err := lifecycle.Commit()
+			assertError(t, err, test.message)
+			if test.message != "" {
+				if _, ok := err.(*ValidationError); ok != (test.logs != "") {
+					t.Errorf("unexpected error type %T", err)
+				}
+			}
+
+			commands := fake.Commands("peer", "lifecycle", "chaincode", "commit")
+			if len(commands) != 1 {
+				t.Fatalf("expected a single commit, got %v", len(commands))
+			}
+			assertCommand(t, commands[0], []string{
+				"peer", "lifecycle", "chaincode", "commit",
+				"--channelID", "mychannel",
+				"--name", "mycc",
+				"--sequence", "3",
+				"-o", "orderer.example.com:7050",
+				"--tls",
+				"--cafile", "/certs/orderer-ca.pem",
+				"--waitForEvent",
+				"--version", "1.0",
+				"--init-required",
+				"--peerAddresses", "peer.org1.example.com:7051",
+				"--tlsRootCertFiles", "/certs/org1.pem",
+				"--peerAddresses", "peer.org2.example.com:7051",
+				"--tlsRootCertFiles", "/certs/org2.pem",
+			})
+			if !reflect.DeepEqual(lifecycle.job.Commit, test.statuses) {
+				t.Errorf("unexpected statuses %+v, want %+v", lifecycle.job.Commit, test.statuses)
+			}
+		})
+	}
+}
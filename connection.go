@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Connection represents the connection options of a chaincode running as external service. TLS material can either be
+// given inline as PEM or as reference to a file.
+type Connection struct {
+	Address            string `json:"address,omitempty"`      // defaults to the chaincode name
+	Port               int    `json:"port,omitempty"`         // defaults to 7052
+	DialTimeout        string `json:"dial_timeout,omitempty"` // defaults to 10s
+	TLSRequired        bool   `json:"tls_required,omitempty"`
+	ClientAuthRequired bool   `json:"client_auth_required,omitempty"`
+	ClientKey          string `json:"client_key,omitempty"`
+	ClientKeyFile      string `json:"client_key_file,omitempty"`
+	ClientCert         string `json:"client_cert,omitempty"`
+	ClientCertFile     string `json:"client_cert_file,omitempty"`
+	RootCert           string `json:"root_cert,omitempty"`
+	RootCertFile       string `json:"root_cert_file,omitempty"`
+}
+
+// Resolve reads the referenced tls files into the inline PEM fields and validates the connection. Files are only read
+// within CONNECTION_ROOT, references are rejected if it is not set.
+func (c *Connection) Resolve() error {
+	root := os.Getenv("CONNECTION_ROOT")
+	for _, material := range []struct {
+		name string
+		pem  *string
+		file *string
+	}{
+		{"client_key_file", &c.ClientKey, &c.ClientKeyFile},
+		{"client_cert_file", &c.ClientCert, &c.ClientCertFile},
+		{"root_cert_file", &c.RootCert, &c.RootCertFile},
+	} {
+		if *material.file == "" {
+			continue
+		}
+		if *material.pem != "" {
+			return fmt.Errorf("%v must not be given inline and as file", material.name)
+		}
+		if root == "" || !within(root, *material.file) {
+			logger.Warnf("Rejected %v %v outside of the connection root %v", material.name, *material.file, root)
+			return fmt.Errorf("%v is not within the connection root", material.name)
+		}
+		raw, err := ioutil.ReadFile(*material.file)
+		if err != nil {
+			// the error is only logged, as it reveals whether the file exists.
+			logger.Warnf("Failed to read %v %v: %v", material.name, *material.file, err)
+			return fmt.Errorf("Failed to read %v", material.name)
+		}
+		*material.pem = string(raw)
+		*material.file = ""
+	}
+
+	if c.DialTimeout != "" {
+		if _, err := time.ParseDuration(c.DialTimeout); err != nil {
+			return fmt.Errorf("Invalid dial timeout %v: %v", c.DialTimeout, err)
+		}
+	}
+	if c.TLSRequired && c.RootCert == "" {
+		return fmt.Errorf("Root cert is required if tls is required")
+	}
+	if c.ClientAuthRequired && (c.ClientKey == "" || c.ClientCert == "") {
+		return fmt.Errorf("Client key and cert are required if client authentication is required")
+	}
+	if c.ClientAuthRequired && !c.TLSRequired {
+		return fmt.Errorf("Client authentication requires tls")
+	}
+	return nil
+}
+
+// references checks whether the connection references files.
+func (c Connection) references() bool {
+	return c.ClientKeyFile != "" || c.ClientCertFile != "" || c.RootCertFile != ""
+}
+
+// UserData builds the connection json of the given chaincode.
+func (c Connection) UserData(chaincode string) ChaincodeServerUserData {
+	address := c.Address
+	if address == "" {
+		address = chaincode
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		// the address does not contain a port yet.
+		port := c.Port
+		if port == 0 {
+			port = 7052
+		}
+		address = net.JoinHostPort(address, strconv.Itoa(port))
+	}
+
+	timeout := c.DialTimeout
+	if timeout == "" {
+		timeout = "10s"
+	}
+
+	return ChaincodeServerUserData{
+		Address:            address,
+		DialTimeout:        timeout,
+		TLSRequired:        c.TLSRequired,
+		ClientAuthRequired: c.ClientAuthRequired,
+		ClientKey:          c.ClientKey,
+		ClientCert:         c.ClientCert,
+		RootCert:           c.RootCert,
+	}
+}
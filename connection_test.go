@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConnectionResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "certs")
+	if err := os.MkdirAll(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join(root, "ca.crt"): "root cert",
+		filepath.Join(dir, "priv_sk"): "private key",
+	} {
+		if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "priv_sk"), filepath.Join(root, "link.key")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		root     string
+		file     string
+		rootCert string
+		message  string
+	}{
+		{name: "file within root", root: root, file: filepath.Join(root, "ca.crt"), rootCert: "root cert"},
+		{name: "no root", file: filepath.Join(root, "ca.crt"), message: "root_cert_file is not within the connection root"},
+		{name: "file outside of root", root: root, file: filepath.Join(dir, "priv_sk"), message: "is not within"},
+		{name: "relative path", root: root, file: filepath.Join(root, "..", "priv_sk"), message: "is not within"},
+		{name: "symbolic link", root: root, file: filepath.Join(root, "link.key"), message: "is not within"},
+		{name: "missing file", root: root, file: filepath.Join(root, "missing.crt"), message: "is not within"},
+	}
+
+	previous := os.Getenv("CONNECTION_ROOT")
+	defer os.Setenv("CONNECTION_ROOT", previous)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("CONNECTION_ROOT", test.root)
+			connection := Connection{RootCertFile: test.file}
+			assertError(t, connection.Resolve(), test.message)
+			if connection.RootCert != test.rootCert {
+				t.Errorf("unexpected root cert %q", connection.RootCert)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsFileReferencesOfOtherOrganizations(t *testing.T) {
+	lifecycle, restore := newTestLifecycle(t, &FakeRunner{}, nil)
+	defer restore()
+	lifecycle.Requester = "Org2MSP"
+
+	request := DeployRequest{Connection: Connection{ClientKeyFile: "/etc/hyperledger/msp/keystore/priv_sk"}}
+	assertError(t, request.apply(&lifecycle), "File references are only accepted from Org1MSP")
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// approvalPolicy is the policy approval requests of other organizations are evaluated against.
+var approvalPolicy = &ApprovalPolicy{}
+
+// ApprovalPolicy describes which approval requests of other organizations are approved without manual consent. Empty
+// lists do not restrict the requests.
+type ApprovalPolicy struct {
+	// Chaincodes are the names of the chaincodes which may be approved, e.g. mycc or fabcar-*.
+	Chaincodes []string `json:"chaincodes,omitempty"`
+	// Requesters are the msp ids of the organizations which may request approvals.
+	Requesters []string `json:"requesters,omitempty"`
+	// PackageHashes are the hex encoded sha256 hashes of the packages which may be approved.
+	PackageHashes []string `json:"package_hashes,omitempty"`
+	// Manual parks every approval request for manual consent.
+	Manual bool `json:"manual,omitempty"`
+	// Sources allows other organizations to install chaincode from source code, which is built and run by the peer.
+	// Such install requests have to match the policy, they cannot be consented manually.
+	Sources bool `json:"sources,omitempty"`
+}
+
+// LoadApprovalPolicy reads and validates the approval policy file.
+func LoadApprovalPolicy(filename string) (*ApprovalPolicy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &ApprovalPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("Invalid approval policy %v: %v", filename, err)
+	}
+	for _, pattern := range policy.Chaincodes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid chaincode pattern %v: %v", pattern, err)
+		}
+	}
+	return policy, nil
+}
+
+// Evaluate returns the reason why the approval request has to be consented manually or an empty string if it may be
+// approved right away.
+func (p *ApprovalPolicy) Evaluate(requester, chaincode, ccid string) string {
+	if p.Manual {
+		return "Manual approval required"
+	}
+	if len(p.Chaincodes) > 0 && !p.allowsChaincode(chaincode) {
+		return fmt.Sprintf("Chaincode %v is not allowed", chaincode)
+	}
+	if len(p.Requesters) > 0 && !contains(p.Requesters, requester) {
+		if requester == "" {
+			return "Requesting organization is unknown"
+		}
+		return fmt.Sprintf("Requesting organization %v is not allowed", requester)
+	}
+	if len(p.PackageHashes) > 0 && !contains(p.PackageHashes, packageHash(ccid)) {
+		return fmt.Sprintf("Package %v is not allowed", ccid)
+	}
+	return ""
+}
+
+func (p *ApprovalPolicy) allowsChaincode(chaincode string) bool {
+	for _, pattern := range p.Chaincodes {
+		if ok, _ := path.Match(pattern, chaincode); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// packageHash returns the hash of the package id {label}:{hash}.
+func packageHash(ccid string) string {
+	return ccid[strings.LastIndex(ccid, ":")+1:]
+}
+
+// consent evaluates the approval request against the approval policy. Requests of the local organization are not
+// evaluated. Returns a PendingError if the request has been parked.
+func (l *Lifecycle) consent() error {
+	if l.Requester == l.MSPID || l.consented {
+		return nil
+	}
+
+	reason := approvalPolicy.Evaluate(l.Requester, l.Chaincode, l.CCID)
+	if reason == "" {
+		return nil
+	}
+
+	approval, err := pending.Add(PendingApproval{
+		Requester:  l.Requester,
+		Channel:    l.Channel,
+		Chaincode:  l.Chaincode,
+		Sequence:   l.Sequence,
+		CCID:       l.CCID,
+		Definition: l.Definition,
+		Reason:     reason,
+	})
+	if err != nil {
+		return err
+	}
+	logger.Warnf("Parked approval %v of %v with ccid %v[%v] on %v requested by %v: %v", approval.ID, l.Chaincode,
+		l.CCID, l.Sequence, l.Channel, l.Requester, reason)
+	return &PendingError{Approval: approval}
+}
+
+// consentSource evaluates install requests of other organizations carrying source code against the approval policy.
+// Returns an AuthError if the source is not accepted.
+func (l *Lifecycle) consentSource() error {
+	if l.Source == nil || l.Requester == l.MSPID {
+		return nil
+	}
+
+	reason := "Source code is not accepted from other organizations"
+	if approvalPolicy.Sources {
+		reason = approvalPolicy.Evaluate(l.Requester, l.Chaincode, l.CCID)
+	}
+	if reason == "" {
+		return nil
+	}
+	logger.Warnf("Refused source of %v with ccid %v requested by %v: %v", l.Chaincode, l.CCID, l.Requester, reason)
+	return &AuthError{http.StatusForbidden, reason}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ChaincodeDefinition represents the parameters of a chaincode definition. All organizations have to approve the same
+// definition before it can be committed.
+type ChaincodeDefinition struct {
+	Version             string       `json:"version,omitempty"` // defaults to 1.0
+	InitRequired        bool         `json:"init_required,omitempty"`
+	SignaturePolicy     string       `json:"signature_policy,omitempty"`
+	ChannelConfigPolicy string       `json:"channel_config_policy,omitempty"`
+	EndorsementPlugin   string       `json:"endorsement_plugin,omitempty"`
+	ValidationPlugin    string       `json:"validation_plugin,omitempty"`
+	Collections         []Collection `json:"collections,omitempty"`
+}
+
+// Collection represents a private data collection in the collections config format of the peer cli.
+type Collection struct {
+	Name              string                       `json:"name"`
+	Policy            string                       `json:"policy"`
+	RequiredPeerCount *int32                       `json:"requiredPeerCount,omitempty"`
+	MaxPeerCount      *int32                       `json:"maxPeerCount,omitempty"`
+	BlockToLive       uint64                       `json:"blockToLive"`
+	MemberOnlyRead    bool                         `json:"memberOnlyRead"`
+	MemberOnlyWrite   bool                         `json:"memberOnlyWrite"`
+	EndorsementPolicy *CollectionEndorsementPolicy `json:"endorsementPolicy,omitempty"`
+}
+
+// CollectionEndorsementPolicy represents the endorsement policy of a collection.
+type CollectionEndorsementPolicy struct {
+	SignaturePolicy     string `json:"signaturePolicy,omitempty"`
+	ChannelConfigPolicy string `json:"channelConfigPolicy,omitempty"`
+}
+
+// Validate sets the default version and checks that the policies and collections can be parsed.
+func (d *ChaincodeDefinition) Validate() error {
+	if d.Version == "" {
+		d.Version = "1.0"
+	}
+	if _, err := d.ValidationParameter(); err != nil {
+		return err
+	}
+	_, err := d.CollectionConfigPackage()
+	return err
+}
+
+// ValidationParameter returns the marshalled endorsement policy or nil if the channel default is used.
+func (d ChaincodeDefinition) ValidationParameter() ([]byte, error) {
+	policy, err := ApplicationPolicy(d.SignaturePolicy, d.ChannelConfigPolicy)
+	if err != nil || policy == nil {
+		return nil, err
+	}
+	return proto.Marshal(policy)
+}
+
+// CollectionConfigPackage converts the collections into the collection config package or nil if there are none.
+func (d ChaincodeDefinition) CollectionConfigPackage() (*peer.CollectionConfigPackage, error) {
+	if len(d.Collections) == 0 {
+		return nil, nil
+	}
+
+	pkg := &peer.CollectionConfigPackage{}
+	for _, collection := range d.Collections {
+		if collection.Name == "" {
+			return nil, fmt.Errorf("Missing collection name")
+		}
+
+		policy, err := ParseSignaturePolicy(collection.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid policy of collection %v: %v", collection.Name, err)
+		}
+
+		var endorsementPolicy *peer.ApplicationPolicy
+		if collection.EndorsementPolicy != nil {
+			endorsementPolicy, err = ApplicationPolicy(collection.EndorsementPolicy.SignaturePolicy, collection.EndorsementPolicy.ChannelConfigPolicy)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid endorsement policy of collection %v: %v", collection.Name, err)
+			}
+		}
+
+		// same defaults as the peer cli.
+		requiredPeerCount, maxPeerCount := int32(0), int32(1)
+		if collection.RequiredPeerCount != nil {
+			requiredPeerCount = *collection.RequiredPeerCount
+		}
+		if collection.MaxPeerCount != nil {
+			maxPeerCount = *collection.MaxPeerCount
+		}
+
+		pkg.Config = append(pkg.Config, &peer.CollectionConfig{
+			Payload: &peer.CollectionConfig_StaticCollectionConfig{
+				StaticCollectionConfig: &peer.StaticCollectionConfig{
+					Name: collection.Name,
+					MemberOrgsPolicy: &peer.CollectionPolicyConfig{
+						Payload: &peer.CollectionPolicyConfig_SignaturePolicy{SignaturePolicy: policy},
+					},
+					RequiredPeerCount: requiredPeerCount,
+					MaximumPeerCount:  maxPeerCount,
+					BlockToLive:       collection.BlockToLive,
+					MemberOnlyRead:    collection.MemberOnlyRead,
+					MemberOnlyWrite:   collection.MemberOnlyWrite,
+					EndorsementPolicy: endorsementPolicy,
+				},
+			},
+		})
+	}
+	return pkg, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
+)
+
+// txWatcher receives the filtered blocks of a peer to observe the validation of a transaction.
+type txWatcher struct {
+	address string
+	conn    *grpc.ClientConn
+	stream  peer.Deliver_DeliverFilteredClient
+}
+
+// watch opens a filtered deliver stream on the target, starting at the newest block of the channel.
+func watch(ctx context.Context, signer *Signer, channel string, target Target) (*txWatcher, error) {
+	conn, err := dial(ctx, target.Address, target.TLSRootCert)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := peer.NewDeliverClient(conn).DeliverFiltered(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	envelope, err := seekEnvelope(signer, channel)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := stream.Send(envelope); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &txWatcher{address: target.Address, conn: conn, stream: stream}, nil
+}
+
+// wait blocks until the transaction is part of a block and returns its validation code.
+func (w *txWatcher) wait(txID string) (TxStatus, error) {
+	for {
+		response, err := w.stream.Recv()
+		if err != nil {
+			return TxStatus{}, fmt.Errorf("Failed to wait for transaction %v at %v: %v", txID, w.address, err)
+		}
+
+		switch r := response.Type.(type) {
+		case *peer.DeliverResponse_FilteredBlock:
+			for _, tx := range r.FilteredBlock.FilteredTransactions {
+				if tx.Txid == txID {
+					return TxStatus{Peer: w.address, TxID: txID, Block: r.FilteredBlock.Number, Code: tx.TxValidationCode.String()}, nil
+				}
+			}
+		case *peer.DeliverResponse_Status:
+			return TxStatus{}, fmt.Errorf("Deliver service of %v returned status %v before transaction %v", w.address, r.Status, txID)
+		}
+	}
+}
+
+func (w *txWatcher) close() {
+	w.stream.CloseSend()
+	w.conn.Close()
+}
+
+// seekEnvelope creates the signed request for all blocks from the newest one on.
+func seekEnvelope(signer *Signer, channel string) (*common.Envelope, error) {
+	seekInfo, err := proto.Marshal(&orderer.SeekInfo{
+		Start:    &orderer.SeekPosition{Type: &orderer.SeekPosition_Newest{Newest: &orderer.SeekNewest{}}},
+		Stop:     &orderer.SeekPosition{Type: &orderer.SeekPosition_Specified{Specified: &orderer.SeekSpecified{Number: math.MaxUint64}}},
+		Behavior: orderer.SeekInfo_BLOCK_UNTIL_READY,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	creator, err := signer.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(append(nonce, creator...))
+
+	certHash, err := tlsCertHash()
+	if err != nil {
+		return nil, err
+	}
+
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:        int32(common.HeaderType_DELIVER_SEEK_INFO),
+		ChannelId:   channel,
+		TxId:        hex.EncodeToString(digest[:]),
+		Timestamp:   ptypes.TimestampNow(),
+		TlsCertHash: certHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{Creator: creator, Nonce: nonce})
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := proto.Marshal(&common.Payload{
+		Header: &common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader},
+		Data:   seekInfo,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &common.Envelope{Payload: payload, Signature: signature}, nil
+}
+
+// tlsCertHash returns the hash of the client certificate, which binds the deliver request to the tls session if the
+// peer requires client authentication.
+func tlsCertHash() ([]byte, error) {
+	if os.Getenv("CORE_PEER_TLS_CLIENTAUTHREQUIRED") != "true" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(os.Getenv("CORE_PEER_TLS_CLIENTCERT_FILE"), os.Getenv("CORE_PEER_TLS_CLIENTKEY_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(cert.Certificate[0])
+	return hash[:], nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import "strings"
+
+// Deploy discovers the network, installs the chaincode on every organization, approves it and commits it to the channel.
+// Each step is recorded on the job of the lifecycle.
+func (l *Lifecycle) Deploy() (err error) {
+	l.job.start()
+	defer func() { l.job.finish(err) }()
+
+	l.job.Infof("Discovering the network")
+	if err := l.job.Run("discover", "", l.Discover); err != nil {
+		return err
+	}
+	l.job.Infof("Found %v nodes", len(l.Nodes))
+
+	if len(l.Definition.Collections) > 0 {
+		l.job.Infof("Validating %v collections", len(l.Definition.Collections))
+		if err := l.job.Run("collections", "", l.ValidateCollections); err != nil {
+			return err
+		}
+	}
+
+	l.job.Infof("Installing %v", l.Chaincode)
+	if err := l.Install(); err != nil {
+		return err
+	}
+	l.job.Infof("Installed chaincode with ccid: %v", l.CCID)
+
+	l.job.Infof("Calculating next sequence number")
+	if err := l.job.Run("sequence", "", l.NextSequence); err != nil {
+		return err
+	}
+	l.job.Infof("Next sequence number: %v", l.Sequence)
+
+	l.job.Infof("Approving %v on %v", l.CCID, l.Channel)
+	if err := l.Approve(); err != nil {
+		return err
+	}
+	l.job.Infof("Successfully approved %v with ccid %v on %v", l.Chaincode, l.CCID, l.Channel)
+
+	if len(l.Definition.Collections) > 0 {
+		l.job.Infof("Verifying the approved collections of %v", strings.Join(l.approvers, ", "))
+		if err := l.job.Run("verify", "", l.VerifyCollections); err != nil {
+			return err
+		}
+	}
+
+	l.job.Infof("Waiting for commit readiness of %v on %v", l.Chaincode, l.Channel)
+	if err := l.job.Run("readiness", "", l.WaitForCommitReadiness); err != nil {
+		return err
+	}
+
+	l.job.Infof("Committing %v to %v", l.CCID, l.Channel)
+	if err := l.job.Run("commit", "", l.Commit); err != nil {
+		return err
+	}
+	l.job.Infof("Successfully committed %v with ccid %v on %v", l.Chaincode, l.CCID, l.Channel)
+
+	if l.Init == nil {
+		return nil
+	}
+	l.job.Infof("Initializing %v on %v", l.Chaincode, l.Channel)
+	if err := l.job.Run("init", "", l.Initialize); err != nil {
+		return err
+	}
+	l.job.Infof("Successfully initialized %v on %v", l.Chaincode, l.Channel)
+	return nil
+}
@@ -1,124 +1,264 @@
 package main
 
 import (
-	"encoding/base64"
+	"bytes"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
-// Node ...
+// Node represents a discovered peer, named and addressed according to the naming of its msp.
 type Node struct {
-	Name   string
-	MSPID  string
-	Host   string
-	RootCA string
+	Name     string
+	MSPID    string
+	Host     string
+	Endpoint string
+	RootCA   string
 }
 
-// NewNode builds a new node.
-func NewNode(mspID, endpoint string) Node {
-	return Node{
-		MSPID: mspID,
-		// the peer endpoint is added like peer-0.peer.host. we need to split the prefixes in order to get the host.
-		Host: strings.Join(strings.Split(strings.Split(endpoint, ":")[0], ".")[2:], "."),
-		Name: strings.Split(endpoint, ".")[0],
+// NewNode builds a new node from the discovered endpoint of a peer.
+func NewNode(mspID, endpoint string) (Node, error) {
+	return naming.For(mspID).node(mspID, endpoint)
+}
+
+// leaders returns the leading node of every organization, which represents the organization during a deployment.
+// Falls back to the node with the lowest endpoint if the leader of an organization has not been discovered.
+func (l *Lifecycle) leaders() []Node {
+	var leaders []Node
+	index := make(map[string]int)
+	for _, node := range l.Nodes {
+		i, ok := index[node.MSPID]
+		if !ok {
+			index[node.MSPID] = len(leaders)
+			leaders = append(leaders, node)
+			continue
+		}
+		leader := naming.For(node.MSPID).Leader
+		if leaders[i].Name == leader {
+			continue
+		}
+		if node.Name == leader || node.Endpoint < leaders[i].Endpoint {
+			leaders[i] = node
+		}
 	}
+	return leaders
 }
 
-// Discover discovers the nodes within the network.
-func (l *Lifecycle) Discover() (err error) {
-	keystore, err := findKeystore()
-	if err != nil {
-		return err
+// lifecycleURL returns the url of the lifecycle service of the node's organization.
+func (l *Lifecycle) lifecycleURL(node Node) (string, error) {
+	n := naming.For(node.MSPID)
+	return n.address(n.LifecycleAddress, node)
+}
+
+// DiscoveredPeer represents a peer as returned by the discovery service.
+type DiscoveredPeer struct {
+	MSPID        string   `json:"MSPID"`
+	Endpoint     string   `json:"Endpoint"`
+	LedgerHeight uint64   `json:"LedgerHeight"`
+	Chaincodes   []string `json:"Chaincodes"`
+	Identity     string   `json:"Identity"` // pem encoded certificate of the peer
+}
+
+// ChannelConfig represents the channel config as returned by the discovery service.
+type ChannelConfig struct {
+	MSPs     map[string]MSPConfig        `json:"msps"`
+	Orderers map[string]OrdererEndpoints `json:"orderers"`
+}
+
+// MSPConfig represents the certificates of a msp of the channel.
+type MSPConfig struct {
+	Name                 string   `json:"name"`
+	RootCerts            [][]byte `json:"root_certs"`
+	IntermediateCerts    [][]byte `json:"intermediate_certs"`
+	Admins               [][]byte `json:"admins"`
+	TLSRootCerts         [][]byte `json:"tls_root_certs"`
+	TLSIntermediateCerts [][]byte `json:"tls_intermediate_certs"`
+	NodeOUs              *NodeOUs `json:"fabric_node_ous"`
+}
+
+// NodeOUs represents the organizational units identifying the roles of the identities of a msp.
+type NodeOUs struct {
+	Enable            bool          `json:"enable"`
+	PeerOUIdentifier  *OUIdentifier `json:"peer_ou_identifier"`
+	AdminOUIdentifier *OUIdentifier `json:"admin_ou_identifier"`
+}
+
+// OUIdentifier represents an organizational unit of a msp.
+type OUIdentifier struct {
+	OrganizationalUnitIdentifier string `json:"organizational_unit_identifier"`
+}
+
+// privileged checks whether the certificate identifies an admin or a peer of the msp. The certificate has to carry the
+// admin or peer organizational unit if node organizational units are enabled, otherwise it has to be an admin cert.
+func (m MSPConfig) privileged(cert *x509.Certificate) bool {
+	for _, admin := range m.Admins {
+		if block, _ := pem.Decode(admin); block != nil && bytes.Equal(block.Bytes, cert.Raw) {
+			return true
+		}
+	}
+	if m.NodeOUs == nil || !m.NodeOUs.Enable {
+		return false
+	}
+	for _, identifier := range []*OUIdentifier{m.NodeOUs.AdminOUIdentifier, m.NodeOUs.PeerOUIdentifier} {
+		if identifier == nil || identifier.OrganizationalUnitIdentifier == "" {
+			continue
+		}
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if ou == identifier.OrganizationalUnitIdentifier {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	signcert, err := findSigncert()
-	if err != nil {
-		return err
+// OrdererEndpoints represents the endpoints of the orderers of a msp.
+type OrdererEndpoints struct {
+	Endpoint []OrdererEndpoint `json:"endpoint"`
+}
+
+// OrdererEndpoint represents the endpoint of an orderer.
+type OrdererEndpoint struct {
+	Host string `json:"host"`
+	Port uint32 `json:"port"`
+}
+
+// validate checks that the peer has the fields required to build a node.
+func (p DiscoveredPeer) validate() error {
+	if p.Endpoint == "" {
+		return fmt.Errorf("Discovered peer of %v is missing its endpoint", p.MSPID)
 	}
+	if p.MSPID == "" {
+		return fmt.Errorf("Discovered peer %v is missing its msp id", p.Endpoint)
+	}
+	return nil
+}
 
-	peers, err := l.peers(keystore, signcert)
-	if err != nil {
-		return err
+// tlsRootCert returns the first tls root cert of the msp.
+func (c ChannelConfig) tlsRootCert(mspID string) ([]byte, error) {
+	msp, ok := c.MSPs[mspID]
+	if !ok {
+		return nil, fmt.Errorf("Channel config is missing msp %v", mspID)
+	}
+	if len(msp.TLSRootCerts) == 0 {
+		return nil, fmt.Errorf("Channel config has no tls root certs for msp %v", mspID)
 	}
+	return msp.TLSRootCerts[0], nil
+}
+
+// Discovery represents the peers and the config of a channel as returned by the discovery service.
+type Discovery struct {
+	Peers  []DiscoveredPeer
+	Config ChannelConfig
+}
 
-	config, err := l.config(keystore, signcert)
+// Discover discovers the nodes within the network.
+func (l *Lifecycle) Discover() (err error) {
+	discovery, err := l.discover()
 	if err != nil {
 		return err
 	}
 
-	for _, peer := range peers {
+	config := discovery.Config
+	for _, peer := range discovery.Peers {
+		if err := peer.validate(); err != nil {
+			return err
+		}
 		// build nodes from peers and config
-		node := NewNode(peer["MSPID"].(string), peer["Endpoint"].(string))
-
-		msp := config["msps"].(map[string]interface{})[node.MSPID].(map[string]interface{})
-		rootCA, err := base64.StdEncoding.DecodeString(msp["tls_root_certs"].([]interface{})[0].(string))
+		node, err := NewNode(peer.MSPID, peer.Endpoint)
 		if err != nil {
-			logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+			return err
 		}
-		rootCAFile, err := ioutil.TempFile("", fmt.Sprintf("%v-%v", node.MSPID, node.Name))
+
+		rootCA, err := config.tlsRootCert(node.MSPID)
 		if err != nil {
 			return err
 		}
-		// saving root ca to file for cli processing.
-		if _, err = rootCAFile.Write(rootCA); err != nil {
+		// root ca has to be provided as file to the cli, hence we save the path to the saved root ca to the node.
+		if node.RootCA, err = discoveries.rootCAFile(node.MSPID, rootCA); err != nil {
 			return err
 		}
-
-		// root ca has to be provided as file to the cli, hence we save the path to the saved root ca to the node.
-		node.RootCA = rootCAFile.Name()
 		l.Nodes = append(l.Nodes, node)
 	}
 
 	return nil
 }
 
-func (l *Lifecycle) peers(keystore, signcert string) (peers []map[string]interface{}, err error) {
+// discover returns the peers and the config of the channel, cached for DISCOVERY_CACHE_TTL.
+func (l *Lifecycle) discover() (Discovery, error) {
+	return discoveries.Load(l.Channel, l.queryDiscovery)
+}
+
+// queryDiscovery queries the peers and the config of the channel from the discovery service of the local peer.
+func (l *Lifecycle) queryDiscovery() (discovery Discovery, err error) {
+	keystore, err := findKeystore(os.Getenv("CORE_PEER_MSPCONFIGPATH"))
+	if err != nil {
+		return discovery, err
+	}
+
+	signcert, err := findSigncert(os.Getenv("CORE_PEER_MSPCONFIGPATH"))
+	if err != nil {
+		return discovery, err
+	}
+
+	if discovery.Peers, err = l.peers(keystore, signcert); err != nil {
+		return discovery, err
+	}
+	discovery.Config, err = l.config(keystore, signcert)
+	return discovery, err
+}
+
+func (l *Lifecycle) peers(keystore, signcert string) (peers []DiscoveredPeer, err error) {
 	command := []string{
-		"discover peers",
-		fmt.Sprintf("--channel %v", l.Channel),
-		fmt.Sprintf("--server %v", os.Getenv("CORE_PEER_ADDRESS")),
-		fmt.Sprintf("--peerTLSCA %v", os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE")),
-		fmt.Sprintf("--userKey %v", filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "keystore", keystore)),
-		fmt.Sprintf("--userCert %v", filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "signcerts", signcert)),
-		fmt.Sprintf("--MSP %v", l.MSPID),
+		"discover", "peers",
+		"--channel", l.Channel,
+		"--server", os.Getenv("CORE_PEER_ADDRESS"),
+		"--peerTLSCA", os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE"),
+		"--userKey", filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "keystore", keystore),
+		"--userCert", filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "signcerts", signcert),
+		"--MSP", l.MSPID,
 	}
 
-	response, err := l.execute(strings.Join(command, " "))
+	response, err := l.execute(command...)
 	if err != nil {
 		return peers, err
 	}
 
-	err = json.Unmarshal(response.Output.Bytes(), &peers)
-	return peers, err
+	if err := json.Unmarshal(response.Output.Bytes(), &peers); err != nil {
+		return peers, fmt.Errorf("Invalid discovered peers of %v: %v", l.Channel, err)
+	}
+	return peers, nil
 }
 
-func (l *Lifecycle) config(keystore, signcert string) (config map[string]interface{}, err error) {
+func (l *Lifecycle) config(keystore, signcert string) (config ChannelConfig, err error) {
 	command := []string{
-		"discover config",
-		fmt.Sprintf("--channel %v", l.Channel),
-		fmt.Sprintf("--server %v", os.Getenv("CORE_PEER_ADDRESS")),
-		fmt.Sprintf("--peerTLSCA %v", os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE")),
-		fmt.Sprintf("--userKey %v", filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "keystore", keystore)),
-		fmt.Sprintf("--userCert %v", filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "signcerts", signcert)),
-		fmt.Sprintf("--MSP %v", l.MSPID),
+		"discover", "config",
+		"--channel", l.Channel,
+		"--server", os.Getenv("CORE_PEER_ADDRESS"),
+		"--peerTLSCA", os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE"),
+		"--userKey", filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "keystore", keystore),
+		"--userCert", filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "signcerts", signcert),
+		"--MSP", l.MSPID,
 	}
 
-	response, err := l.execute(strings.Join(command, " "))
+	response, err := l.execute(command...)
 	if err != nil {
 		return config, err
 	}
 
-	err = json.Unmarshal(response.Output.Bytes(), &config)
-	return config, err
+	if err := json.Unmarshal(response.Output.Bytes(), &config); err != nil {
+		return config, fmt.Errorf("Invalid discovered config of %v: %v", l.Channel, err)
+	}
+	return config, nil
 }
 
-func findKeystore() (string, error) {
+func findKeystore(mspConfigPath string) (string, error) {
 	// read keystore file as the name is generated
-	files, err := ioutil.ReadDir(filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "keystore"))
+	files, err := ioutil.ReadDir(filepath.Join(mspConfigPath, "keystore"))
 	if err != nil {
 		return "", err
 	}
@@ -128,9 +268,9 @@ func findKeystore() (string, error) {
 	return files[0].Name(), nil
 }
 
-func findSigncert() (string, error) {
+func findSigncert(mspConfigPath string) (string, error) {
 	// read signcerts file as the name is generated
-	files, err := ioutil.ReadDir(filepath.Join(os.Getenv("CORE_PEER_MSPCONFIGPATH"), "signcerts"))
+	files, err := ioutil.ReadDir(filepath.Join(mspConfigPath, "signcerts"))
 	if err != nil {
 		return "", err
 	}
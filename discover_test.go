@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name    string
+		peers   string
+		config  string
+		nodes   []Node
+		roots   map[string]string
+		message string
+	}{
+		{
+			name:   "peers of all organizations",
+			peers:  recorded(t, "discover_peers.json"),
+			config: recorded(t, "discover_config.json"),
+			nodes: []Node{
+				{Name: "peer-1", MSPID: "Org2MSP", Host: "org2.example.com", Endpoint: "peer-1.peer.org2.example.com:7051"},
+				{Name: "peer-0", MSPID: "Org1MSP", Host: "org1.example.com", Endpoint: "peer-0.peer.org1.example.com:7051"},
+				{Name: "peer-0", MSPID: "Org2MSP", Host: "org2.example.com", Endpoint: "peer-0.peer.org2.example.com:7051"},
+			},
+			roots: map[string]string{"Org1MSP": "TLSCAORG1", "Org2MSP": "TLSCAORG2"},
+		},
+		{
+			name:    "msp missing in config",
+			peers:   recorded(t, "discover_peers.json"),
+			config:  `{"msps": {}}`,
+			message: "Channel config is missing msp Org2MSP",
+		},
+		{
+			name:    "peer without endpoint",
+			peers:   `[{"MSPID": "Org1MSP"}]`,
+			config:  recorded(t, "discover_config.json"),
+			message: "Discovered peer of Org1MSP is missing its endpoint",
+		},
+		{
+			name:    "malformed peers",
+			peers:   `Error: access denied`,
+			message: "Invalid discovered peers of mychannel",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := (&FakeRunner{}).
+				On(test.peers, "", nil, "discover", "peers").
+				On(test.config, "", nil, "discover", "config")
+			lifecycle, restore := newTestLifecycle(t, fake, nil)
+			defer restore()
+
+			err := lifecycle.Discover()
+			assertError(t, err, test.message)
+
+			msp := os.Getenv("CORE_PEER_MSPCONFIGPATH")
+			for i, command := range fake.Calls {
+				assertCommand(t, command, []string{
+					"discover", []string{"peers", "config"}[i],
+					"--channel", "mychannel",
+					"--server", "peer-0.peer.org1.example.com:7051",
+					"--peerTLSCA", "/certs/peer-ca.pem",
+					"--userKey", filepath.Join(msp, "keystore", "priv_sk"),
+					"--userCert", filepath.Join(msp, "signcerts", "cert.pem"),
+					"--MSP", "Org1MSP",
+				})
+			}
+			if err != nil {
+				return
+			}
+
+			if len(lifecycle.Nodes) != len(test.nodes) {
+				t.Fatalf("expected %v nodes, got %v", len(test.nodes), len(lifecycle.Nodes))
+			}
+			for i, node := range lifecycle.Nodes {
+				rootCA, err := ioutil.ReadFile(node.RootCA)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected := pemOf(test.roots[node.MSPID]); string(rootCA) != expected {
+					t.Errorf("unexpected root ca of %v: %q", node.Endpoint, rootCA)
+				}
+				node.RootCA = ""
+				if node != test.nodes[i] {
+					t.Errorf("unexpected node %+v, want %+v", node, test.nodes[i])
+				}
+			}
+
+			leaders := lifecycle.leaders()
+			if len(leaders) != 2 || leaders[0].Endpoint != "peer-0.peer.org2.example.com:7051" ||
+				leaders[1].Endpoint != "peer-0.peer.org1.example.com:7051" {
+				t.Errorf("unexpected leaders %+v", leaders)
+			}
+		})
+	}
+}
+
+func TestDiscoverConfig(t *testing.T) {
+	var config ChannelConfig
+	if err := json.Unmarshal([]byte(recorded(t, "discover_config.json")), &config); err != nil {
+		t.Fatal(err)
+	}
+	root, err := config.tlsRootCert("Org1MSP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(root) != pemOf("TLSCAORG1") {
+		t.Errorf("unexpected tls root cert %q", root)
+	}
+	if _, err := config.tlsRootCert("Org3MSP"); err == nil {
+		t.Error("expected an error for an unknown msp")
+	}
+}
+
+// pemOf returns the recorded pem certificate of the given name.
+func pemOf(name string) string {
+	return "-----BEGIN CERTIFICATE-----\nMIIB" + name + "\n-----END CERTIFICATE-----\n"
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The types of job events.
+const (
+	EventStep      = "step"
+	EventMilestone = "milestone"
+	EventDone      = "done"
+)
+
+// subscriberBuffer is the number of events a subscriber may lag behind before it gets disconnected.
+const subscriberBuffer = 64
+
+// Event represents a progress update of a job.
+type Event struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message,omitempty"`
+	State   string    `json:"state,omitempty"`
+	Step    *Step     `json:"step,omitempty"`
+}
+
+// Infof logs the milestone and publishes it to the subscribers of the job.
+func (j *Job) Infof(template string, args ...interface{}) {
+	logger.Infof(template, args...)
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.publish(Event{Type: EventMilestone, Message: fmt.Sprintf(template, args...)})
+}
+
+// Subscribe returns the past events of the job and a channel receiving the upcoming events. The channel is closed once
+// the job has finished. The returned function has to be called to unsubscribe.
+func (j *Job) Subscribe() ([]Event, <-chan Event, func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	events := make([]Event, len(j.events))
+	copy(events, j.events)
+
+	subscriber := make(chan Event, subscriberBuffer)
+	if j.Finished != nil {
+		close(subscriber)
+		return events, subscriber, func() {}
+	}
+
+	if j.subscribers == nil {
+		j.subscribers = make(map[chan Event]struct{})
+	}
+	j.subscribers[subscriber] = struct{}{}
+
+	return events, subscriber, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subscribers[subscriber]; ok {
+			delete(j.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// publish records the event and sends it to all subscribers. Has to be called while holding the lock of the job.
+func (j *Job) publish(event Event) {
+	event.Time = time.Now()
+	if event.Step != nil {
+		// steps are updated in place, hence a copy is published.
+		step := *event.Step
+		event.Step = &step
+	}
+	j.events = append(j.events, event)
+
+	for subscriber := range j.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			// disconnect subscribers which are not keeping up.
+			logger.Warnf("Dropping slow subscriber of job %v", j.ID)
+			delete(j.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// JobEvents streams the events of a deployment job as server-sent events until the job has finished.
+func JobEvents(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	job, ok := jobs.Get(id)
+	if !ok {
+		logger.Warnf("Job %v could not be found", id)
+		http.Error(w, fmt.Sprintf("Job %v could not be found", id), http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// a subscriber which is not keeping up gets disconnected, the stream then resumes from the recorded events.
+	sent := 0
+	for {
+		done, err := streamEvents(w, flusher, req, job, &sent)
+		if err != nil || done {
+			return
+		}
+		logger.Debugf("Resuming events of job %v after event %v", id, sent)
+	}
+}
+
+// streamEvents writes the events of the job from the given position until the job has finished or the subscription
+// has been dropped. Returns true once the done event has been sent.
+func streamEvents(w http.ResponseWriter, flusher http.Flusher, req *http.Request, job *Job, sent *int) (bool, error) {
+	past, upcoming, unsubscribe := job.Subscribe()
+	defer unsubscribe()
+
+	write := func(event Event) error {
+		if err := writeEvent(w, event); err != nil {
+			return err
+		}
+		*sent++
+		return nil
+	}
+
+	done := false
+	for _, event := range past[*sent:] {
+		if err := write(event); err != nil {
+			return false, err
+		}
+		done = event.Type == EventDone
+	}
+	flusher.Flush()
+	if done {
+		return true, nil
+	}
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return false, req.Context().Err()
+		case event, ok := <-upcoming:
+			if !ok {
+				return false, nil
+			}
+			if err := write(event); err != nil {
+				return false, err
+			}
+			flusher.Flush()
+			if event.Type == EventDone {
+				return true, nil
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %v\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// blockingRecorder records the response, writes block until the recorder is released.
+type blockingRecorder struct {
+	*httptest.ResponseRecorder
+	writing  chan struct{}
+	released chan struct{}
+	once     sync.Once
+}
+
+func newBlockingRecorder() *blockingRecorder {
+	return &blockingRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		writing:          make(chan struct{}),
+		released:         make(chan struct{}),
+	}
+}
+
+func (r *blockingRecorder) Write(data []byte) (int, error) {
+	r.once.Do(func() { close(r.writing) })
+	<-r.released
+	return r.ResponseRecorder.Write(data)
+}
+
+// streamed parses the server-sent events of the body.
+func streamed(t *testing.T, body string) []Event {
+	var events []Event
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+			var event Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestJobEventsResumesDroppedSubscribers(t *testing.T) {
+	previous := jobs
+	defer func() { jobs = previous }()
+	jobs = NewJobs()
+	job := jobs.Add("mychannel", "mycc")
+	job.Infof("milestone %v", 0)
+
+	w := newBlockingRecorder()
+	req := httptest.NewRequest("GET", "/jobs/"+job.ID+"/events", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": job.ID})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		JobEvents(w, req)
+	}()
+
+	// the handler has subscribed and is stuck writing the first event, the following events overflow its buffer.
+	<-w.writing
+	for i := 1; i <= 2*subscriberBuffer; i++ {
+		job.Infof("milestone %v", i)
+	}
+	job.finish(nil)
+	close(w.released)
+	<-finished
+
+	events := streamed(t, w.Body.String())
+	if len(events) != 2*subscriberBuffer+2 {
+		t.Fatalf("expected %v events, got %v", 2*subscriberBuffer+2, len(events))
+	}
+	for i, event := range events[:len(events)-1] {
+		if expected := fmt.Sprintf("milestone %v", i); event.Type != EventMilestone || event.Message != expected {
+			t.Errorf("expected %v, got %v %v", expected, event.Type, event.Message)
+		}
+	}
+	if done := events[len(events)-1]; done.Type != EventDone || done.State != StateSucceeded {
+		t.Errorf("expected the done event last, got %+v", done)
+	}
+}
+
+func TestJobSubscribersReceiveAllEvents(t *testing.T) {
+	job := NewJobs().Add("mychannel", "mycc")
+	job.Infof("before subscribing")
+
+	past, first, unsubscribeFirst := job.Subscribe()
+	defer unsubscribeFirst()
+	_, second, unsubscribeSecond := job.Subscribe()
+	defer unsubscribeSecond()
+	if len(past) != 1 || past[0].Message != "before subscribing" {
+		t.Errorf("expected the past event to be replayed, got %v", past)
+	}
+
+	job.Run("install", "Org1MSP", func() error { return nil })
+	job.finish(nil)
+
+	for _, subscriber := range []<-chan Event{first, second} {
+		var types []string
+		for event := range subscriber {
+			types = append(types, event.Type)
+		}
+		if expected := []string{EventStep, EventStep, EventDone}; fmt.Sprint(types) != fmt.Sprint(expected) {
+			t.Errorf("expected events %v, got %v", expected, types)
+		}
+	}
+
+	past, late, _ := job.Subscribe()
+	if _, ok := <-late; ok || len(past) != 4 || past[3].Type != EventDone {
+		t.Errorf("expected a late subscriber to get all events and a closed channel, got %v", past)
+	}
+}
+
+func TestJobEventsReplaysFinishedJobs(t *testing.T) {
+	previous := jobs
+	defer func() { jobs = previous }()
+	jobs = NewJobs()
+	job := jobs.Add("mychannel", "mycc")
+	job.Run("install", "Org1MSP", func() error { return fmt.Errorf("exit status 1") })
+	job.finish(fmt.Errorf("exit status 1"))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/jobs/"+job.ID+"/events", nil), map[string]string{"id": job.ID})
+	w := httptest.NewRecorder()
+	JobEvents(w, req)
+
+	if content := w.Header().Get("Content-Type"); content != "text/event-stream" {
+		t.Errorf("unexpected content type %v", content)
+	}
+	events := streamed(t, w.Body.String())
+	if len(events) != 3 || events[1].Step.State != StateFailed || events[2].Type != EventDone || events[2].Message != "exit status 1" {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if !strings.Contains(w.Body.String(), "event: done\n") {
+		t.Errorf("expected the event type to be sent, got %v", w.Body.String())
+	}
+
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/jobs/unknown/events", nil), map[string]string{"id": "unknown"})
+	w = httptest.NewRecorder()
+	JobEvents(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown job not to be found, got %v", w.Code)
+	}
+}
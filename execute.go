@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"regexp"
 )
@@ -13,8 +15,22 @@ type Response struct {
 	Logs   bytes.Buffer
 }
 
-func (l *Lifecycle) execute(command string) (Response, error) {
-	cmd := exec.Command("bash", "-c", fmt.Sprintf(command))
+// Runner runs a command given as argv with additional environment variables.
+type Runner interface {
+	Run(ctx context.Context, env []string, argv ...string) (Response, error)
+}
+
+// ExecRunner runs commands as local processes.
+type ExecRunner struct{}
+
+// Run executes the command and collects stdout as output and stderr as logs.
+func (ExecRunner) Run(ctx context.Context, env []string, argv ...string) (Response, error) {
+	if len(argv) == 0 {
+		return Response{}, fmt.Errorf("Missing command")
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), env...)
 	var outb, errb bytes.Buffer
 	cmd.Stdout = &outb
 	cmd.Stderr = &errb
@@ -26,6 +42,14 @@ func (l *Lifecycle) execute(command string) (Response, error) {
 	return Response{Output: outb, Logs: errb}, err
 }
 
+func (l *Lifecycle) execute(argv ...string) (Response, error) {
+	return l.executeWithEnv(nil, argv...)
+}
+
+func (l *Lifecycle) executeWithEnv(env []string, argv ...string) (Response, error) {
+	return l.Runner.Run(l.context(), env, argv...)
+}
+
 func (res Response) findInLogs(regex string) (string, error) {
 	r, err := regexp.Compile(regex)
 	if err != nil {
@@ -34,3 +58,12 @@ func (res Response) findInLogs(regex string) (string, error) {
 
 	return r.FindString(res.Logs.String()), nil
 }
+
+func (res Response) findAllInLogs(regex string) ([][]string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindAllStringSubmatch(res.Logs.String(), -1), nil
+}
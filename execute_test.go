@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// FakeCall is a scripted response of the fake runner.
+type FakeCall struct {
+	Prefix []string
+	Output string
+	Logs   string
+	Err    error
+	Effect func(argv []string) // e.g. writes the files created by the command
+}
+
+// FakeRunner replays scripted outputs instead of running commands. A call is answered by the first script whose
+// prefix matches the argv of the command. All executed commands are recorded in Calls.
+type FakeRunner struct {
+	mu      sync.Mutex
+	Scripts []FakeCall
+	Calls   [][]string
+}
+
+// On scripts the output, logs and error returned for commands starting with prefix.
+func (f *FakeRunner) On(output, logs string, err error, prefix ...string) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Scripts = append(f.Scripts, FakeCall{Prefix: prefix, Output: output, Logs: logs, Err: err})
+	return f
+}
+
+// Do scripts the effect of commands starting with prefix, which otherwise succeed without output.
+func (f *FakeRunner) Do(effect func(argv []string), prefix ...string) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Scripts = append(f.Scripts, FakeCall{Prefix: prefix, Effect: effect})
+	return f
+}
+
+// Run records the command and returns the scripted response.
+func (f *FakeRunner) Run(ctx context.Context, env []string, argv ...string) (Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, argv)
+
+	for _, script := range f.Scripts {
+		if !hasPrefix(argv, script.Prefix) {
+			continue
+		}
+		if script.Effect != nil {
+			script.Effect(argv)
+		}
+		var response Response
+		response.Output.WriteString(script.Output)
+		response.Logs.WriteString(script.Logs)
+		return response, script.Err
+	}
+
+	return Response{}, fmt.Errorf("No script found for %v", strings.Join(argv, " "))
+}
+
+// Commands returns the recorded commands starting with prefix.
+func (f *FakeRunner) Commands(prefix ...string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var commands [][]string
+	for _, call := range f.Calls {
+		if hasPrefix(call, prefix) {
+			commands = append(commands, call)
+		}
+	}
+	return commands
+}
+
+func hasPrefix(argv, prefix []string) bool {
+	if len(prefix) > len(argv) {
+		return false
+	}
+	for i := range prefix {
+		if argv[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// recorded returns the content of a recorded peer output in testdata.
+func recorded(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// newTestLifecycle builds a lifecycle of Org1MSP on mychannel whose runner and cli backend replay the fake runner.
+// The returned function restores the environment and the package state.
+func newTestLifecycle(t *testing.T, fake *FakeRunner, vars map[string]string) (Lifecycle, func()) {
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msp := filepath.Join(dir, "msp")
+	for _, file := range []string{"keystore/priv_sk", "signcerts/cert.pem"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(msp, file)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(msp, file), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := map[string]string{
+		"CORE_PEER_LOCALMSPID":        "Org1MSP",
+		"CORE_PEER_MSPCONFIGPATH":     msp,
+		"CORE_PEER_ADDRESS":           "peer-0.peer.org1.example.com:7051",
+		"CORE_PEER_TLS_ROOTCERT_FILE": "/certs/peer-ca.pem",
+		"ORDERER_ADDRESS":             "orderer.example.com:7050",
+		"ORDERER_CA":                  "/certs/orderer-ca.pem",
+	}
+	previous := make(map[string]string)
+	for key, value := range env {
+		previous[key] = os.Getenv(key)
+		os.Setenv(key, value)
+	}
+	previousWorkdir, previousDiscoveries, previousNaming := workdir, discoveries, naming
+	workdir = NewWorkDir(filepath.Join(dir, "work"))
+	discoveries = NewDiscoveryCache(0)
+	naming = &NamingConfig{}
+
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	if _, ok := vars["channel"]; !ok {
+		vars["channel"] = "mychannel"
+	}
+	if _, ok := vars["chaincode"]; !ok {
+		vars["chaincode"] = "mycc"
+	}
+	lifecycle := NewLifecycle(context.Background(), vars)
+	lifecycle.Runner = fake
+	lifecycle.Backend = &CLIBackend{Runner: fake}
+
+	return lifecycle, func() {
+		for key, value := range previous {
+			os.Setenv(key, value)
+		}
+		workdir, discoveries, naming = previousWorkdir, previousDiscoveries, previousNaming
+		os.RemoveAll(dir)
+	}
+}
+
+func assertCommand(t *testing.T, actual, expected []string) {
+	t.Helper()
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected command\n got: %v\nwant: %v", strings.Join(actual, " "), strings.Join(expected, " "))
+	}
+}
+
+func assertError(t *testing.T, err error, expected string) {
+	t.Helper()
+	if expected == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected error containing %q, got %v", expected, err)
+	}
+}
@@ -3,12 +3,14 @@ module github.com/holzeis/lifecycle
 go 1.13
 
 require (
+	github.com/golang/protobuf v1.3.3
 	github.com/google/uuid v1.1.1
 	github.com/gorilla/mux v1.7.4
 	github.com/hyperledger/fabric v2.0.1+incompatible
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200124220212-e9cfc186ba7b
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/stretchr/testify v1.5.1 // indirect
 	github.com/sykesm/zap-logfmt v0.0.3 // indirect
 	go.uber.org/zap v1.14.1 // indirect
-	google.golang.org/grpc v1.28.0 // indirect
+	google.golang.org/grpc v1.28.0
 )
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// InitRequest represents the init invocation of a chaincode which requires initialization.
+type InitRequest struct {
+	Function string   `json:"function,omitempty"` // defaults to Init
+	Args     []string `json:"args,omitempty"`
+}
+
+// Validate sets the default function name.
+func (r *InitRequest) Validate() error {
+	if r.Function == "" {
+		r.Function = "Init"
+	}
+	return nil
+}
+
+// Initialize invokes the init function of the committed chaincode on one peer of every discovered organization and
+// waits for the validation of the transaction.
+func (l *Lifecycle) Initialize() error {
+	if l.Init == nil {
+		return fmt.Errorf("Missing init request")
+	}
+
+	targets, err := l.endorsingTargets()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := l.Backend.Init(l.context(), l.Channel, l.Chaincode, l.Init.Function, l.Init.Args, targets)
+	l.job.setInit(statuses)
+	for _, status := range statuses {
+		if status.Block > 0 {
+			l.job.Infof("%v validated the init in block %v with status %v", status.Peer, status.Block, status.Code)
+		} else {
+			l.job.Infof("%v validated the init with status %v", status.Peer, status.Code)
+		}
+	}
+	return err
+}
+
+// Init invokes the init function of a committed chaincode, e.g. to retry a failed init of a deployment. Returns the
+// validation result per peer.
+func Init(w http.ResponseWriter, req *http.Request) {
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
+
+	var request InitRequest
+	if err := decodeRequest(req, &request); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if err := request.Validate(); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if err := lifecycle.Discover(); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	targets, err := lifecycle.endorsingTargets()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	statuses, err := lifecycle.Backend.Init(lifecycle.context(), lifecycle.Channel, lifecycle.Chaincode, request.Function, request.Args, targets)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully initialized %v on %v", lifecycle.Chaincode, lifecycle.Channel)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(statuses); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+	}
+}
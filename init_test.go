@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestInitialize(t *testing.T) {
+	txID := "9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d"
+	tests := []struct {
+		name    string
+		request InitRequest
+		ctor    string
+	}{
+		{name: "default function", request: InitRequest{Args: []string{"a", "100"}}, ctor: `{"Args":["Init","a","100"]}`},
+		{name: "custom function", request: InitRequest{Function: "InitLedger"}, ctor: `{"Args":["InitLedger"]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := (&FakeRunner{}).On("", recorded(t, "init_valid.log"), nil, "peer", "chaincode", "invoke")
+			lifecycle, restore := newTestLifecycle(t, fake, nil)
+			defer restore()
+			lifecycle.Nodes = []Node{
+				{Name: "peer-0", MSPID: "Org1MSP", Host: "org1.example.com", RootCA: "/certs/org1.pem"},
+				{Name: "peer-1", MSPID: "Org2MSP", Host: "org2.example.com", RootCA: "/certs/org2.pem"},
+				{Name: "peer-0", MSPID: "Org2MSP", Host: "org2.example.com", RootCA: "/certs/org2.pem"},
+			}
+			request := test.request
+			if err := request.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			lifecycle.Init = &request
+			lifecycle.job = NewJobs().Add(lifecycle.Channel, lifecycle.Chaincode)
+
+			if err := lifecycle.Initialize(); err != nil {
+				t.Fatal(err)
+			}
+
+			commands := fake.Commands("peer", "chaincode", "invoke")
+			if len(commands) != 1 {
+				t.Fatalf("expected a single invoke, got %v", len(commands))
+			}
+			assertCommand(t, commands[0], []string{
+				"peer", "chaincode", "invoke",
+				"--channelID", "mychannel",
+				"--name", "mycc",
+				"--isInit",
+				"--ctor", test.ctor,
+				"-o", "orderer.example.com:7050",
+				"--tls",
+				"--cafile", "/certs/orderer-ca.pem",
+				"--waitForEvent",
+				"--peerAddresses", "peer.org1.example.com:7051",
+				"--tlsRootCertFiles", "/certs/org1.pem",
+				"--peerAddresses", "peer.org2.example.com:7051",
+				"--tlsRootCertFiles", "/certs/org2.pem",
+			})
+			expected := []TxStatus{
+				{Peer: "peer.org1.example.com:7051", TxID: txID, Code: "VALID"},
+				{Peer: "peer.org2.example.com:7051", TxID: txID, Code: "VALID"},
+			}
+			if !reflect.DeepEqual(lifecycle.job.Init, expected) {
+				t.Errorf("unexpected statuses %+v, want %+v", lifecycle.job.Init, expected)
+			}
+		})
+	}
+}
+
+func TestInitHandler(t *testing.T) {
+	fake := (&FakeRunner{}).
+		On(recorded(t, "discover_peers.json"), "", nil, "discover", "peers").
+		On(recorded(t, "discover_config.json"), "", nil, "discover", "config").
+		On("", recorded(t, "init_valid.log"), nil, "peer", "chaincode", "invoke")
+	_, restore := newTestLifecycle(t, fake, nil)
+	defer restore()
+	previousRunner, previousBackend := runner, backend
+	defer func() { runner, backend = previousRunner, previousBackend }()
+	runner, backend = fake, &CLIBackend{Runner: fake}
+
+	req := httptest.NewRequest("POST", "/mychannel/init/mycc", bytes.NewReader([]byte("{}")))
+	req = mux.SetURLVars(req, map[string]string{"channel": "mychannel", "chaincode": "mycc"})
+	w := httptest.NewRecorder()
+	Init(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", w.Code, w.Body.String())
+	}
+	var statuses []TxStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Code != "VALID" || statuses[1].Peer != "peer.org2.example.com:7051" {
+		t.Errorf("expected the status of every organization, got %+v", statuses)
+	}
+
+	commands := fake.Commands("peer", "chaincode", "invoke")
+	if len(commands) != 1 || commands[0][9] != `{"Args":["Init"]}` {
+		t.Errorf("expected Init to be invoked, got %v", commands)
+	}
+}
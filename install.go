@@ -4,11 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"path/filepath"
-	"strings"
-
-	"github.com/google/uuid"
 )
 
 // ChaincodeServerUserData represents the connection json structure.
@@ -29,114 +25,135 @@ type PackageMetadata struct {
 	Label string `json:"label"`
 }
 
-func (l *Lifecycle) createMetadata(path string) error {
-	metadataAsBytes, err := json.Marshal(PackageMetadata{
-		Label: l.Chaincode,
-		Path:  "",
-		Type:  "external",
-	})
-	if err != nil {
-		return err
-	}
-	if err := ioutil.WriteFile(fmt.Sprintf("%v/metadata.json", path), metadataAsBytes, 0644); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (l *Lifecycle) createConnection(path string) error {
-	connectionAsBytes, err := json.Marshal(ChaincodeServerUserData{
-		Address:     fmt.Sprintf("%v:7052", l.Chaincode),
-		DialTimeout: "10s",
-		TLSRequired: false,
-	})
+// Install installs the chaincode to the network using the nodes discovered by the discovery service. Performs a http request for each msp which is not the current.
+func (l *Lifecycle) Install() error {
+	body, err := json.Marshal(l.request())
 	if err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(fmt.Sprintf("%v/connection.json", path), connectionAsBytes, 0644); err != nil {
-		return err
-	}
-	return nil
-}
 
-// Install installs the chaincode to the network using the nodes discovered by the discovery service. Performs a http request for each msp which is not the current.
-func (l *Lifecycle) Install() error {
-	for _, node := range l.Nodes {
-		if node.Name != "peer-0" {
-			continue
-		}
-		if node.MSPID == l.MSPID {
-			// if msp is local msp, no need to make an http request
-			if err := l.install(); err != nil {
-				return err
+	for _, node := range l.leaders() {
+		err := l.job.Run("install", node.MSPID, func() error {
+			if node.MSPID == l.MSPID {
+				// if msp is local msp, no need to make an http request
+				return l.install()
 			}
-		} else {
 			// ask participants to approve the chaincode installation
-			resp, err := http.Get(fmt.Sprintf("%v://lifecycle.%v:%v/install/%v", "http", node.Host, "8090", l.Chaincode))
+			resp, err := l.post(node, fmt.Sprintf("/install/%v", l.Chaincode), body)
 			if err != nil {
 				return err
 			}
+			defer resp.Body.Close()
 			if resp.StatusCode != 200 {
 				return fmt.Errorf("%v returned status code %v", node.MSPID, resp.StatusCode)
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-		logger.Infof("%v successfully installed the chaincode", node.MSPID)
+		l.job.Infof("%v successfully installed the chaincode", node.MSPID)
 	}
 
 	return nil
 }
 
 func (l *Lifecycle) install() error {
-	txid := uuid.New()
-	path, err := ioutil.TempDir("", txid.String())
+	pkg, err := l.Package()
 	if err != nil {
 		return err
 	}
-
-	if err := l.createConnection(path); err != nil {
+	l.CCID = PackageID(l.Chaincode, pkg)
+	if err := l.consentSource(); err != nil {
 		return err
 	}
 
-	if err := l.createMetadata(path); err != nil {
-		return err
-	}
-
-	if _, err := l.execute(fmt.Sprintf("tar -czf %[1]v/code.tar.gz -C %[1]v connection.json", path)); err != nil {
-		return err
-	}
-
-	if _, err := l.execute(fmt.Sprintf("tar -czf %[1]v/%[2]v.tgz -C %[1]v code.tar.gz metadata.json", path, l.Chaincode)); err != nil {
+	targets, err := l.installTargets()
+	if err != nil {
 		return err
 	}
 
-	// TODO: config root shouldn't be hardcoded here.
-	configroot := "/artifacts/crypto-config"
-	for _, node := range l.Nodes {
-		admin, err := findAdmin(filepath.Join(configroot, node.Name, "msp/users/"))
+	for _, target := range targets {
+		installed, err := l.isInstalled(target)
 		if err != nil {
 			return err
 		}
-		command := []string{
-			fmt.Sprintf("CORE_PEER_MSPCONFIGPATH=%v", filepath.Join(configroot, node.Name, "msp/users", admin, "msp")),
-			"peer lifecycle chaincode install",
-			fmt.Sprintf("%v/%v.tgz", path, l.Chaincode),
-			fmt.Sprintf("--peerAddresses %v.peer.%v:%v", node.Name, node.Host, "7051"),
-			fmt.Sprintf("--tlsRootCertFiles %v", filepath.Join(configroot, node.Name, "tls/ca-cert.pem")),
+		if installed {
+			logger.Infof("%v is already installed on %v", l.CCID, target.Address)
+			continue
 		}
 
-		response, err := l.execute(strings.Join(command, " "))
+		packageID, err := l.Backend.Install(l.context(), target, pkg)
 		if err != nil {
 			return err
 		}
 
-		if node.Name == "peer-0" {
-			l.CCID, _ = response.findInLogs(fmt.Sprintf("%v:\\w*", l.Chaincode))
+		if packageID == "" {
+			// the peer did not report the package id, hence we verify the installed chaincodes.
+			if installed, err = l.isInstalled(target); err != nil {
+				return err
+			}
+			if !installed {
+				return fmt.Errorf("%v does not list %v as installed", target.Address, l.CCID)
+			}
+		} else if packageID != l.CCID {
+			return fmt.Errorf("%v reported package id %v, but %v was expected", target.Address, packageID, l.CCID)
 		}
 	}
 
 	return nil
 }
 
+// installTargets returns the peers of the local organization to install the chaincode on. Falls back to the local
+// peer if the network has not been discovered.
+func (l *Lifecycle) installTargets() ([]Target, error) {
+	if len(l.Nodes) == 0 {
+		return []Target{localTarget()}, nil
+	}
+
+	var targets []Target
+	for _, node := range l.Nodes {
+		if node.MSPID != l.MSPID {
+			// other organizations install the chaincode on their own peers.
+			continue
+		}
+
+		n := naming.For(node.MSPID)
+		address, err := n.address(n.PeerAddress, node)
+		if err != nil {
+			return nil, err
+		}
+		configroot, err := n.address(n.CryptoConfig, node)
+		if err != nil {
+			return nil, err
+		}
+		admin, err := findAdmin(filepath.Join(configroot, "msp/users/"))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, Target{
+			Address:       address,
+			TLSRootCert:   filepath.Join(configroot, "tls/ca-cert.pem"),
+			MSPConfigPath: filepath.Join(configroot, "msp/users", admin, "msp"),
+		})
+	}
+	return targets, nil
+}
+
+// isInstalled checks whether the package with the ccid of the lifecycle is installed on the target.
+func (l *Lifecycle) isInstalled(target Target) (bool, error) {
+	installed, err := l.Backend.QueryInstalled(l.context(), target)
+	if err != nil {
+		return false, err
+	}
+	for _, chaincode := range installed {
+		if chaincode.PackageID == l.CCID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func findAdmin(path string) (string, error) {
 	files, err := ioutil.ReadDir(path)
 	if err != nil {
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstall(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed string
+		logs      string
+		installs  int
+		queries   int
+		message   string
+	}{
+		{
+			name:      "install reporting the package id",
+			installed: recorded(t, "queryinstalled.json"),
+			logs:      recorded(t, "install.log"),
+			installs:  1,
+			queries:   1,
+		},
+		{
+			name:      "already installed",
+			installed: `{"installed_chaincodes": [{"package_id": "{{ccid}}", "label": "mycc"}]}`,
+			queries:   1,
+		},
+		{
+			name:      "install without package id not listing the package",
+			installed: recorded(t, "queryinstalled.json"),
+			installs:  1,
+			queries:   2,
+			message:   "peer-0.peer.org1.example.com:7051 does not list mycc:",
+		},
+		{
+			name:      "install reporting another package id",
+			installed: recorded(t, "queryinstalled.json"),
+			logs:      strings.Replace(recorded(t, "install.log"), "{{ccid}}", "mycc:"+strings.Repeat("0", 64), -1),
+			installs:  1,
+			queries:   1,
+			message:   "reported package id mycc:0000",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &FakeRunner{}
+			lifecycle, restore := newTestLifecycle(t, fake, nil)
+			defer restore()
+			lifecycle.Connection = Connection{Address: "mycc.org1.example.com"}
+
+			pkg, err := lifecycle.Package()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ccid := PackageID("mycc", pkg)
+			fake.On(strings.Replace(test.installed, "{{ccid}}", ccid, -1), "", nil, "peer", "lifecycle", "chaincode", "queryinstalled").
+				On("", strings.Replace(test.logs, "{{ccid}}", ccid, -1), nil, "peer", "lifecycle", "chaincode", "install")
+
+			err = lifecycle.install()
+			assertError(t, err, test.message)
+			if lifecycle.CCID != ccid {
+				t.Errorf("unexpected ccid %v, want %v", lifecycle.CCID, ccid)
+			}
+
+			queries := fake.Commands("peer", "lifecycle", "chaincode", "queryinstalled")
+			if len(queries) != test.queries {
+				t.Fatalf("expected %v queries, got %v", test.queries, len(queries))
+			}
+			for _, command := range queries {
+				assertCommand(t, command, []string{
+					"peer", "lifecycle", "chaincode", "queryinstalled",
+					"--peerAddresses", "peer-0.peer.org1.example.com:7051",
+					"--tlsRootCertFiles", "/certs/peer-ca.pem",
+					"-O", "json",
+				})
+			}
+
+			installs := fake.Commands("peer", "lifecycle", "chaincode", "install")
+			if len(installs) != test.installs {
+				t.Fatalf("expected %v installs, got %v", test.installs, len(installs))
+			}
+			for _, command := range installs {
+				if !strings.HasSuffix(command[4], ".tgz") {
+					t.Errorf("expected the package as file, got %v", command[4])
+				}
+				assertCommand(t, append(command[:4:4], command[5:]...), []string{
+					"peer", "lifecycle", "chaincode", "install",
+					"--peerAddresses", "peer-0.peer.org1.example.com:7051",
+					"--tlsRootCertFiles", "/certs/peer-ca.pem",
+				})
+			}
+		})
+	}
+}
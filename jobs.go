@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// The states of jobs and steps.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+// jobRetention defines how long finished jobs can be polled.
+const jobRetention = 24 * time.Hour
+
+// jobs keeps track of all deployment jobs.
+var jobs = NewJobs()
+
+// Step represents a single step of a job.
+type Step struct {
+	Name     string     `json:"name"`
+	MSPID    string     `json:"mspid,omitempty"`
+	State    string     `json:"state"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Job represents a deployment running in the background.
+type Job struct {
+	mu sync.Mutex
+
+	ID        string     `json:"id"`
+	Channel   string     `json:"channel"`
+	Chaincode string     `json:"chaincode"`
+	CCID      string     `json:"ccid,omitempty"`
+	Sequence  int        `json:"sequence,omitempty"`
+	State     string     `json:"state"`
+	Created   time.Time  `json:"created"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Steps     []*Step    `json:"steps"`
+
+	// Collections is the private data collection config approved by all organizations.
+	Collections []Collection `json:"collections,omitempty"`
+	// Commit contains the validation result of the commit transaction per peer.
+	Commit []TxStatus `json:"commit,omitempty"`
+	// Init contains the validation result of the init transaction per peer.
+	Init []TxStatus `json:"init,omitempty"`
+
+	events      []Event
+	subscribers map[chan Event]struct{}
+	rejections  []Decision
+	parked      map[string]bool
+}
+
+// Run runs fn as step of the job and records its state. A nil job just runs fn.
+func (j *Job) Run(name, mspID string, fn func() error) error {
+	if j == nil {
+		return fn()
+	}
+
+	j.mu.Lock()
+	started := time.Now()
+	step := &Step{Name: name, MSPID: mspID, State: StateRunning, Started: &started}
+	j.Steps = append(j.Steps, step)
+	j.publish(Event{Type: EventStep, Step: step})
+	j.mu.Unlock()
+
+	err := fn()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	finished := time.Now()
+	step.Finished = &finished
+	step.State = StateSucceeded
+	if err != nil {
+		step.State = StateFailed
+		step.Error = err.Error()
+	}
+	j.publish(Event{Type: EventStep, Step: step})
+	return err
+}
+
+func (j *Job) setCommit(statuses []TxStatus) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Commit = statuses
+}
+
+func (j *Job) setInit(statuses []TxStatus) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Init = statuses
+}
+
+// approving records the ccid and the sequence of the definition approved by the job.
+func (j *Job) approving(ccid string, sequence int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.CCID = ccid
+	j.Sequence = sequence
+}
+
+// park records that the organization parked its approval for manual consent.
+func (j *Job) park(mspID string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.parked == nil {
+		j.parked = make(map[string]bool)
+	}
+	j.parked[mspID] = true
+}
+
+// parkedApprovals returns the number of approvals waiting for manual consent. A nil job has no parked approvals.
+func (j *Job) parkedApprovals() int {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.parked)
+}
+
+// awaits checks whether the job waits for the decision on the parked approval.
+func (j *Job) awaits(approval PendingApproval) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.CCID == approval.CCID && j.Sequence == approval.Sequence
+}
+
+// decide records the decision of an organization on its parked approval.
+func (j *Job) decide(decision Decision) {
+	j.mu.Lock()
+	delete(j.parked, decision.MSPID)
+	if decision.Decision == DecisionRejected {
+		j.rejections = append(j.rejections, decision)
+	}
+	j.mu.Unlock()
+
+	message := fmt.Sprintf("%v %v the approval", decision.MSPID, decision.Decision)
+	if decision.Reason != "" {
+		message += ": " + decision.Reason
+	}
+	j.Infof("%v", message)
+}
+
+// rejection returns an error if an organization rejected its parked approval. A nil job has no rejections.
+func (j *Job) rejection() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.rejections) == 0 {
+		return nil
+	}
+	decision := j.rejections[0]
+	if decision.Reason == "" {
+		return fmt.Errorf("%v rejected the approval", decision.MSPID)
+	}
+	return fmt.Errorf("%v rejected the approval: %v", decision.MSPID, decision.Reason)
+}
+
+func (j *Job) start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.State = StateRunning
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	finished := time.Now()
+	j.Finished = &finished
+	j.State = StateSucceeded
+	if err != nil {
+		j.State = StateFailed
+		j.Error = err.Error()
+	}
+	j.publish(Event{Type: EventDone, State: j.State, Message: j.Error})
+	for subscriber := range j.subscribers {
+		close(subscriber)
+	}
+	j.subscribers = nil
+}
+
+// MarshalJSON marshals a consistent snapshot of the job.
+func (j *Job) MarshalJSON() ([]byte, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	type job Job
+	steps := make([]Step, len(j.Steps))
+	for i, step := range j.Steps {
+		steps[i] = *step
+	}
+	return json.Marshal(struct {
+		*job
+		Steps []Step `json:"steps"`
+	}{(*job)(j), steps})
+}
+
+// Jobs is an in-memory store of jobs.
+type Jobs struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewJobs builds a new job store.
+func NewJobs() *Jobs {
+	return &Jobs{jobs: make(map[string]*Job)}
+}
+
+// Add creates a new pending job and drops finished jobs older than the retention.
+func (s *Jobs) Add(channel, chaincode string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, job := range s.jobs {
+		job.mu.Lock()
+		expired := job.Finished != nil && time.Since(*job.Finished) > jobRetention
+		job.mu.Unlock()
+		if expired {
+			delete(s.jobs, id)
+		}
+	}
+
+	job := &Job{
+		ID:        uuid.New().String(),
+		Channel:   channel,
+		Chaincode: chaincode,
+		State:     StatePending,
+		Created:   time.Now(),
+		Steps:     []*Step{},
+	}
+	s.jobs[job.ID] = job
+	return job
+}
+
+// Get returns the job with the given id.
+func (s *Jobs) Get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// Running returns the unfinished jobs of the chaincode on the channel.
+func (s *Jobs) Running(channel, chaincode string) []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var running []*Job
+	for _, job := range s.jobs {
+		job.mu.Lock()
+		if job.Channel == channel && job.Chaincode == chaincode && job.Finished == nil {
+			running = append(running, job)
+		}
+		job.mu.Unlock()
+	}
+	return running
+}
+
+// GetJob returns the state of a deployment job.
+func GetJob(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	job, ok := jobs.Get(id)
+	if !ok {
+		logger.Warnf("Job %v could not be found", id)
+		http.Error(w, fmt.Sprintf("Job %v could not be found", id), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestJobStateTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		state string
+	}{
+		{name: "succeeded", state: StateSucceeded},
+		{name: "failed", err: fmt.Errorf("Timed out waiting for approvals of Org2MSP"), state: StateFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := NewJobs().Add("mychannel", "mycc")
+			if job.State != StatePending {
+				t.Errorf("expected a new job to be pending, got %v", job.State)
+			}
+			job.start()
+			if job.State != StateRunning {
+				t.Errorf("expected a started job to be running, got %v", job.State)
+			}
+
+			if err := job.Run("install", "Org1MSP", func() error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+			err := job.Run("commit", "", func() error { return test.err })
+			if err != test.err {
+				t.Errorf("expected the error of the step, got %v", err)
+			}
+			job.finish(err)
+
+			if job.State != test.state || job.Finished == nil {
+				t.Errorf("expected a finished job in state %v, got %v", test.state, job.State)
+			}
+			if test.err != nil && job.Error != test.err.Error() {
+				t.Errorf("expected error %v, got %v", test.err, job.Error)
+			}
+			if len(job.Steps) != 2 || job.Steps[0].State != StateSucceeded || job.Steps[0].MSPID != "Org1MSP" {
+				t.Fatalf("unexpected steps %+v", job.Steps)
+			}
+			if commit := job.Steps[1]; commit.State != test.state || commit.Started == nil || commit.Finished == nil {
+				t.Errorf("unexpected commit step %+v", commit)
+			}
+		})
+	}
+}
+
+func TestJobsDropExpiredJobs(t *testing.T) {
+	store := NewJobs()
+	expired := store.Add("mychannel", "mycc")
+	retained := store.Add("mychannel", "mycc")
+	running := store.Add("mychannel", "mycc")
+	old := time.Now().Add(-jobRetention - time.Minute)
+	recent := time.Now().Add(-jobRetention + time.Minute)
+	expired.Finished, retained.Finished, running.Created = &old, &recent, old
+
+	store.Add("mychannel", "othercc")
+
+	if _, ok := store.Get(expired.ID); ok {
+		t.Error("expected the job finished before the retention to be dropped")
+	}
+	for _, job := range []*Job{retained, running} {
+		if _, ok := store.Get(job.ID); !ok {
+			t.Errorf("expected job %v to be retained", job.ID)
+		}
+	}
+	if jobs := store.Running("mychannel", "mycc"); len(jobs) != 1 || jobs[0] != running {
+		t.Errorf("expected only the unfinished job to be running, got %v", jobs)
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	previous := jobs
+	defer func() { jobs = previous }()
+	jobs = NewJobs()
+	job := jobs.Add("mychannel", "mycc")
+	job.Run("install", "Org1MSP", func() error { return nil })
+
+	for _, test := range []struct {
+		id     string
+		status int
+	}{
+		{id: job.ID, status: http.StatusOK},
+		{id: "unknown", status: http.StatusNotFound},
+	} {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/jobs/"+test.id, nil), map[string]string{"id": test.id})
+		w := httptest.NewRecorder()
+		GetJob(w, req)
+		if w.Code != test.status {
+			t.Fatalf("expected status %v, got %v", test.status, w.Code)
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		var polled struct {
+			ID    string `json:"id"`
+			State string `json:"state"`
+			Steps []Step `json:"steps"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &polled); err != nil {
+			t.Fatal(err)
+		}
+		if polled.ID != job.ID || polled.State != StatePending || len(polled.Steps) != 1 || polled.Steps[0].Name != "install" {
+			t.Errorf("unexpected job %+v", polled)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signJWT builds a token with the header and claims signed by the key.
+func signJWT(t *testing.T, header, claims map[string]interface{}, key crypto.Signer) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(signature[32-len(rb):32], rb)
+		copy(signature[64-len(sb):], sb)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testJWKS writes a json web key set with the public keys and loads it.
+func testJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) map[string]crypto.PublicKey {
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	jwks := JWKS{Keys: []JWK{
+		{Kid: "rsa", Kty: "RSA", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+		{Kid: "ec", Kty: "EC", Crv: "P-256", X: encode(ecKey.X), Y: encode(ecKey.Y)},
+	}}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadJWKS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := testJWKS(t, rsaKey, ecKey)
+
+	now := time.Now().Unix()
+	valid := map[string]interface{}{"sub": "ci", "exp": now + 600}
+	tests := []struct {
+		name    string
+		token   string
+		message string
+	}{
+		{name: "rs256", token: signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, valid, rsaKey)},
+		{name: "es256", token: signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec"}, valid, ecKey)},
+		{
+			name:  "within leeway",
+			token: signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec"}, map[string]interface{}{"exp": now - 30, "nbf": now + 30}, ecKey),
+		},
+		{
+			name:    "expired",
+			token:   signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec"}, map[string]interface{}{"exp": now - 120}, ecKey),
+			message: "Token has expired",
+		},
+		{
+			name:    "without expiry",
+			token:   signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec"}, map[string]interface{}{"sub": "ci"}, ecKey),
+			message: "Token has expired",
+		},
+		{
+			name:    "not valid yet",
+			token:   signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec"}, map[string]interface{}{"exp": now + 600, "nbf": now + 300}, ecKey),
+			message: "Token is not valid yet",
+		},
+		{
+			name:    "wrong alg for rsa key",
+			token:   signJWT(t, map[string]interface{}{"alg": "HS256", "kid": "rsa"}, valid, rsaKey),
+			message: "Unsupported algorithm HS256",
+		},
+		{
+			name:    "wrong alg for ec key",
+			token:   signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "ec"}, valid, ecKey),
+			message: "Unsupported algorithm RS256",
+		},
+		{
+			name:    "none alg",
+			token:   unsigned(t, "ec", valid),
+			message: "Unsupported algorithm none",
+		},
+		{
+			name:    "unknown kid",
+			token:   signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "other"}, valid, otherKey),
+			message: "Unknown key other",
+		},
+		{
+			name:    "missing kid with several keys",
+			token:   signJWT(t, map[string]interface{}{"alg": "ES256"}, valid, ecKey),
+			message: "Unknown key",
+		},
+		{
+			name:    "bad signature",
+			token:   signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec"}, valid, otherKey),
+			message: "Invalid token signature",
+		},
+		{
+			name:    "bad rsa signature",
+			token:   signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, valid, mustRSAKey(t)),
+			message: "Invalid token signature",
+		},
+		{name: "malformed", token: "header.claims", message: "Malformed token"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := verifyJWT(test.token, keys)
+			assertError(t, err, test.message)
+			if err == nil && claims["exp"] == nil {
+				t.Errorf("expected the claims of the token, got %v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyJWTWithoutKidAndSingleKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]crypto.PublicKey{"ec": &key.PublicKey}
+	token := signJWT(t, map[string]interface{}{"alg": "ES256"}, map[string]interface{}{"exp": time.Now().Unix() + 600}, key)
+	if _, err := verifyJWT(token, keys); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// unsigned builds a token with alg none and an empty signature.
+func unsigned(t *testing.T, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]interface{}{"alg": "none", "kid": kid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
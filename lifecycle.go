@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -17,19 +19,41 @@ import (
 
 var logger = flogging.MustGetLogger("lifecycle")
 
+// runner is used by every lifecycle to run the cli commands.
+var runner Runner = ExecRunner{}
+
+// backend is used by every lifecycle to perform the lifecycle operations.
+var backend Backend = &CLIBackend{Runner: runner}
+
 // Lifecycle keeping all data required for the lifecycle cli commands
 type Lifecycle struct {
 	MSPID string
+	// Requester is the msp id of the organization which requested the operation.
+	Requester string
 
 	Channel   string
 	Chaincode string
 	Sequence  int
 	CCID      string
 	Nodes     []Node
+
+	Definition ChaincodeDefinition
+	Connection Connection
+	Source     *Source
+	Init       *InitRequest
+	prebuilt   []byte
+	consented  bool
+	// approvers are the msps which approved the definition during the deployment.
+	approvers []string
+
+	Runner  Runner
+	Backend Backend
+	ctx     context.Context
+	job     *Job
 }
 
 // NewLifecycle builds a new lifecycle struct
-func NewLifecycle(vars map[string]string) Lifecycle {
+func NewLifecycle(ctx context.Context, vars map[string]string) Lifecycle {
 	sequence := 1
 	if seq, ok := vars["sequence"]; ok {
 		sequence, _ = strconv.Atoi(seq)
@@ -39,78 +63,124 @@ func NewLifecycle(vars map[string]string) Lifecycle {
 		Chaincode: vars["chaincode"],
 		Channel:   vars["channel"],
 		MSPID:     os.Getenv("CORE_PEER_LOCALMSPID"),
+		Requester: os.Getenv("CORE_PEER_LOCALMSPID"),
 		Sequence:  sequence,
 		CCID:      vars["ccid"],
+		Definition: ChaincodeDefinition{
+			Version: "1.0",
+		},
+		Runner:  runner,
+		Backend: backend,
+		ctx:     ctx,
 	}
 }
 
-// Deploy deploys a chaincode as external service to the network.
+func (l *Lifecycle) context() context.Context {
+	if l.ctx == nil {
+		return context.Background()
+	}
+	return l.ctx
+}
+
+// Deploy deploys a chaincode as external service to the network. The deployment runs in the background, the returned
+// job can be polled for its progress.
 func Deploy(w http.ResponseWriter, req *http.Request) {
-	lifecycle := NewLifecycle(mux.Vars(req))
+	lifecycle := NewLifecycle(context.Background(), mux.Vars(req))
 
-	logger.Info("Discovering the network")
-	if err := lifecycle.Discover(); err != nil {
+	request, err := parseDeployRequest(w, req)
+	if err != nil {
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
-		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
 		return
 	}
-	logger.Infof("Found %v nodes", len(lifecycle.Nodes))
-
-	logger.Infof("Installing %v", lifecycle.Chaincode)
-	if err := lifecycle.Install(); err != nil {
+	if err := request.apply(&lifecycle); err != nil {
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
-		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
 		return
 	}
-	logger.Infof("Installed chaincode with ccid: %v", lifecycle.CCID)
 
-	logger.Info("Calculating next sequence number")
-	if err := lifecycle.NextSequence(); err != nil {
+	lifecycle.job = jobs.Add(lifecycle.Channel, lifecycle.Chaincode)
+	lifecycle.job.Collections = lifecycle.Definition.Collections
+
+	go func() {
+		if err := lifecycle.Deploy(); err != nil {
+			logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(lifecycle.job); err != nil {
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
-		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
-		return
 	}
-	logger.Infof("Next sequence number: %v", lifecycle.Sequence)
+}
+
+// Install installs a chaincode to the given peer. Installs the chaincode as external service unless source code is given.
+func Install(w http.ResponseWriter, req *http.Request) {
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
+	lifecycle.Requester = requester(req)
 
-	logger.Infof("Approving %v on %v", lifecycle.CCID, lifecycle.Channel)
-	if err := lifecycle.Approve(); err != nil {
+	request, err := parseDeployRequest(w, req)
+	if err != nil {
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
-		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
 		return
 	}
-	logger.Infof("Successfully approved %v with ccid %v on %v", lifecycle.Chaincode, lifecycle.CCID, lifecycle.Channel)
-
-	logger.Infof("Committing %v to %v", lifecycle.CCID, lifecycle.Channel)
-	if err := lifecycle.Commit(); err != nil {
+	if err := request.apply(&lifecycle); err != nil {
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
-		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
 	}
-}
 
-// Install installs a chaincode as external service to the given peer.
-func Install(w http.ResponseWriter, req *http.Request) {
-	lifecycle := NewLifecycle(mux.Vars(req))
 	if err := lifecycle.install(); err != nil {
+		status := http.StatusInternalServerError
+		if e, ok := err.(*AuthError); ok {
+			status = e.Status
+		}
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
-		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), status)
+		return
 	}
 	logger.Infof("Successfully installed %v with ccid %v", lifecycle.Chaincode, lifecycle.CCID)
 }
 
-// Approve approves the given chaincode for the given channel and ccid.
+// Approve approves the given chaincode for the given channel and ccid. The chaincode definition can be given in the body.
 func Approve(w http.ResponseWriter, req *http.Request) {
-	lifecycle := NewLifecycle(mux.Vars(req))
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
+
+	var request ApproveRequest
+	if err := decodeRequest(req, &request); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if err := request.Definition.Validate(); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+	lifecycle.Definition = request.Definition
+	lifecycle.Requester = requester(req)
 
 	if err := lifecycle.approve(); err != nil {
+		if e, ok := err.(*PendingError); ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			if err := json.NewEncoder(w).Encode(e.Approval); err != nil {
+				logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+			}
+			return
+		}
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
 		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
 	}
 	logger.Infof("Successfully approved %v with ccid %v[%v] on %v", lifecycle.Chaincode, lifecycle.CCID, lifecycle.Sequence, lifecycle.Channel)
 }
 
 // Installed returns the ccid of the requested chaincode and channel.
 func Installed(w http.ResponseWriter, req *http.Request) {
-	lifecycle := NewLifecycle(mux.Vars(req))
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
 
 	if err := lifecycle.GetCCID(); err != nil {
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
@@ -128,9 +198,9 @@ func Installed(w http.ResponseWriter, req *http.Request) {
 
 // Joined returns ok if the peer has joined the given channel.
 func Joined(w http.ResponseWriter, req *http.Request) {
-	lifecycle := NewLifecycle(mux.Vars(req))
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
 
-	response, err := lifecycle.execute("peer channel list")
+	response, err := lifecycle.execute("peer", "channel", "list")
 	if err != nil {
 		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
 		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
@@ -159,16 +229,82 @@ func Joined(w http.ResponseWriter, req *http.Request) {
 }
 
 func main() {
+	var err error
+	if backend, err = NewBackend(runner); err != nil {
+		logger.Fatal(err)
+	}
+	ttl, err := discoveryCacheTTL()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	discoveries = NewDiscoveryCache(ttl)
+	if path := os.Getenv("NAMING_CONFIG"); path != "" {
+		if naming, err = LoadNamingConfig(path); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if path := os.Getenv("APPROVAL_POLICY"); path != "" {
+		if approvalPolicy, err = LoadApprovalPolicy(path); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if path := os.Getenv("ACCESS_CONTROL"); path != "" {
+		if access, err = LoadAccessControl(path); err != nil {
+			logger.Fatal(err)
+		}
+		if os.Getenv("REMOTE_AUTH") == "disabled" {
+			// other organizations could call the endpoints of the access control unauthenticated.
+			logger.Fatal("ACCESS_CONTROL requires the authentication of other organizations, unset REMOTE_AUTH")
+		}
+	}
+	if path := os.Getenv("REGISTRY_CONFIG"); path != "" {
+		if registry, err = LoadRegistry(path); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	if root := os.Getenv("WORK_DIR"); root != "" {
+		workdir = NewWorkDir(root)
+	}
+	if err := workdir.Clean(); err != nil {
+		logger.Fatal(err)
+	}
+	queue := os.Getenv("APPROVAL_QUEUE")
+	if queue == "" {
+		// parked approvals have to survive restarts, the requesting organizations keep waiting for them.
+		queue = filepath.Join(workdir.Root, "approvals.json")
+	}
+	if pending, err = LoadApprovalQueue(queue); err != nil {
+		logger.Fatal(err)
+	}
+
 	r := mux.NewRouter()
-	r.HandleFunc("/{channel}/deploy/{chaincode}", Deploy).Methods("GET")
-	r.HandleFunc("/install/{chaincode}", Install).Methods("GET")
-	r.HandleFunc("/{channel}/approve/{chaincode}/{sequence}/{ccid}", Approve).Methods("GET")
-	r.HandleFunc("/{channel}/installed/{chaincode}", Installed).Methods("GET")
-	r.HandleFunc("/{channel}/joined", Joined).Methods("GET")
-	server := &http.Server{Addr: ":8090", Handler: r}
+	r.Handle("/{channel}/deploy/{chaincode}", Authorize(RoleDeployer, Deploy)).Methods("POST")
+	r.Handle("/status", Authorize(RoleViewer, Status)).Methods("GET")
+	r.Handle("/registry", Authorize(RoleViewer, GetRegistry)).Methods("GET")
+	r.Handle("/registry/{mspid}", Authorize(RoleDeployer, PutRegistry)).Methods("PUT")
+	r.Handle("/registry/{mspid}", Authorize(RoleDeployer, DeleteRegistry)).Methods("DELETE")
+	r.Handle("/approvals/pending", Authorize(RoleViewer, GetPendingApprovals)).Methods("GET")
+	r.Handle("/approvals/{id}/accept", AuthorizeScope(RoleApprover, approvalScope, AcceptApproval)).Methods("POST")
+	r.Handle("/approvals/{id}/reject", AuthorizeScope(RoleApprover, approvalScope, RejectApproval)).Methods("POST")
+	r.Handle("/jobs/{id}", Authorize(RoleViewer, GetJob)).Methods("GET")
+	r.Handle("/jobs/{id}/events", Authorize(RoleViewer, JobEvents)).Methods("GET")
+	r.Handle("/install/{chaincode}", AuthorizeOrganization(RoleInstaller, Install)).Methods("GET", "POST")
+	r.Handle("/package/{chaincode}/id", Authorize(RoleViewer, PackageIDHandler)).Methods("GET", "POST")
+	r.Handle("/packages", Authorize(RoleInstaller, UploadPackage)).Methods("POST")
+	r.Handle("/packages/{packageID}", Authorize(RoleViewer, DownloadPackage)).Methods("GET")
+	r.Handle("/{channel}/approve/{chaincode}/{sequence}/{ccid}", AuthorizeOrganization(RoleApprover, Approve)).Methods("GET", "POST")
+	r.Handle("/{channel}/decisions/{chaincode}", AuthorizeOrganization(RoleDeployer, Decide)).Methods("POST")
+	r.Handle("/{channel}/init/{chaincode}", Authorize(RoleDeployer, Init)).Methods("POST")
+	r.Handle("/{channel}/installed/{chaincode}", Authorize(RoleViewer, Installed)).Methods("GET")
+	r.Handle("/{channel}/joined", Authorize(RoleViewer, Joined)).Methods("GET")
+	r.Handle("/{channel}/topology", Authorize(RoleViewer, GetTopology)).Methods("GET")
+	r.Handle("/{channel}/topology/refresh", Authorize(RoleDeployer, RefreshTopology)).Methods("POST")
+	server, err := NewServer(r)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	go func() {
-		logger.Info("Listening on 0.0.0.0:8090")
 		logger.Fatal(server.ListenAndServe())
 	}()
 
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"text/template"
+)
+
+// naming describes how the peers and lifecycle services of the organizations are addressed.
+var naming = &NamingConfig{}
+
+// defaultNaming follows the kubernetes naming scheme the lifecycle service has been built for, where peers are
+// discovered as peer-0.peer.<host>:7051.
+var defaultNaming = Naming{
+	EndpointPattern:  `^(?P<name>[^.:]+)\.[^.:]+\.(?P<host>[^:]+)`,
+	Leader:           "peer-0",
+	PeerAddress:      "{{.Name}}.peer.{{.Host}}:7051",
+	EndorserAddress:  "peer.{{.Host}}:7051",
+	LifecycleAddress: "http://lifecycle.{{.Host}}:8090",
+	CryptoConfig:     "/artifacts/crypto-config/{{.Name}}",
+}
+
+// Naming describes how the peers and the lifecycle service of an organization are addressed. Addresses are templates
+// executed with the discovered node, e.g. {{.Name}}, {{.Host}}, {{.MSPID}} or {{.Endpoint}}.
+type Naming struct {
+	// EndpointPattern is matched against the discovered peer endpoints, the groups name and host set the name and the
+	// host of the node.
+	EndpointPattern string `json:"endpoint_pattern,omitempty"`
+	// Leader is the name of the peer representing the organization. Defaults to its first peer if it does not exist.
+	Leader string `json:"leader,omitempty"`
+	// PeerAddress is the address the chaincode is installed on.
+	PeerAddress string `json:"peer_address,omitempty"`
+	// EndorserAddress is the address of the peer endorsing the commit and the init of the organization.
+	EndorserAddress string `json:"endorser_address,omitempty"`
+	// LifecycleAddress is the url of the lifecycle service of the organization.
+	LifecycleAddress string `json:"lifecycle_address,omitempty"`
+	// CryptoConfig is the folder holding the tls ca and the admin users of a local peer.
+	CryptoConfig string `json:"crypto_config,omitempty"`
+}
+
+// NamingConfig represents the naming configuration file. Settings of a msp override the default settings, which
+// override the built-in kubernetes naming scheme.
+type NamingConfig struct {
+	Default Naming            `json:"default"`
+	MSPs    map[string]Naming `json:"msps"`
+}
+
+// LoadNamingConfig reads and validates the naming configuration file.
+func LoadNamingConfig(path string) (*NamingConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &NamingConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("Invalid naming config %v: %v", path, err)
+	}
+	if err := config.For("").validate(); err != nil {
+		return nil, fmt.Errorf("Invalid default naming: %v", err)
+	}
+	for mspID := range config.MSPs {
+		if err := config.For(mspID).validate(); err != nil {
+			return nil, fmt.Errorf("Invalid naming of %v: %v", mspID, err)
+		}
+	}
+	return config, nil
+}
+
+// For returns the naming of the msp.
+func (c *NamingConfig) For(mspID string) Naming {
+	return defaultNaming.merge(c.Default).merge(c.MSPs[mspID])
+}
+
+func (n Naming) merge(other Naming) Naming {
+	if other.EndpointPattern != "" {
+		n.EndpointPattern = other.EndpointPattern
+	}
+	if other.Leader != "" {
+		n.Leader = other.Leader
+	}
+	if other.PeerAddress != "" {
+		n.PeerAddress = other.PeerAddress
+	}
+	if other.EndorserAddress != "" {
+		n.EndorserAddress = other.EndorserAddress
+	}
+	if other.LifecycleAddress != "" {
+		n.LifecycleAddress = other.LifecycleAddress
+	}
+	if other.CryptoConfig != "" {
+		n.CryptoConfig = other.CryptoConfig
+	}
+	return n
+}
+
+func (n Naming) validate() error {
+	if _, err := regexp.Compile(n.EndpointPattern); err != nil {
+		return fmt.Errorf("invalid endpoint pattern: %v", err)
+	}
+	for _, text := range []string{n.PeerAddress, n.EndorserAddress, n.LifecycleAddress, n.CryptoConfig} {
+		if _, err := template.New("address").Option("missingkey=error").Parse(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// node builds the node of a discovered peer endpoint.
+func (n Naming) node(mspID, endpoint string) (Node, error) {
+	pattern, err := regexp.Compile(n.EndpointPattern)
+	if err != nil {
+		return Node{}, err
+	}
+	match := pattern.FindStringSubmatch(endpoint)
+	if match == nil {
+		return Node{}, fmt.Errorf("Discovered peer %v of %v does not match %v", endpoint, mspID, n.EndpointPattern)
+	}
+
+	node := Node{MSPID: mspID, Endpoint: endpoint, Name: endpoint}
+	for i, group := range pattern.SubexpNames() {
+		switch group {
+		case "name":
+			node.Name = match[i]
+		case "host":
+			node.Host = match[i]
+		}
+	}
+	return node, nil
+}
+
+// address executes the address template with the node.
+func (n Naming) address(text string, node Node) (string, error) {
+	t, err := template.New("address").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, node); err != nil {
+		return "", fmt.Errorf("Failed to address %v: %v", node.Endpoint, err)
+	}
+	return buf.String(), nil
+}
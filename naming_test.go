@@ -0,0 +1,240 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNamingFor(t *testing.T) {
+	config := &NamingConfig{
+		Default: Naming{Leader: "peer-1", LifecycleAddress: "https://lifecycle.{{.Host}}"},
+		MSPs: map[string]Naming{
+			"Org2MSP": {Leader: "peer0", PeerAddress: "{{.Endpoint}}"},
+		},
+	}
+
+	tests := []struct {
+		mspID    string
+		expected Naming
+	}{
+		{
+			mspID: "Org1MSP",
+			expected: Naming{
+				EndpointPattern:  defaultNaming.EndpointPattern,
+				Leader:           "peer-1",
+				PeerAddress:      defaultNaming.PeerAddress,
+				EndorserAddress:  defaultNaming.EndorserAddress,
+				LifecycleAddress: "https://lifecycle.{{.Host}}",
+				CryptoConfig:     defaultNaming.CryptoConfig,
+			},
+		},
+		{
+			mspID: "Org2MSP",
+			expected: Naming{
+				EndpointPattern:  defaultNaming.EndpointPattern,
+				Leader:           "peer0",
+				PeerAddress:      "{{.Endpoint}}",
+				EndorserAddress:  defaultNaming.EndorserAddress,
+				LifecycleAddress: "https://lifecycle.{{.Host}}",
+				CryptoConfig:     defaultNaming.CryptoConfig,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mspID, func(t *testing.T) {
+			if n := config.For(test.mspID); !reflect.DeepEqual(n, test.expected) {
+				t.Errorf("unexpected naming %+v, want %+v", n, test.expected)
+			}
+		})
+	}
+
+	if n := (&NamingConfig{}).For("Org1MSP"); !reflect.DeepEqual(n, defaultNaming) {
+		t.Errorf("expected the kubernetes naming scheme without config, got %+v", n)
+	}
+}
+
+func TestNamingNode(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		endpoint string
+		expected Node
+		message  string
+	}{
+		{
+			name:     "kubernetes",
+			endpoint: "peer-0.peer.org1.example.com:7051",
+			expected: Node{Name: "peer-0", Host: "org1.example.com"},
+		},
+		{
+			name:     "named groups",
+			pattern:  `^(?P<name>peer\d+)\.(?P<host>[^:]+):\d+$`,
+			endpoint: "peer0.org1.example.com:7051",
+			expected: Node{Name: "peer0", Host: "org1.example.com"},
+		},
+		{
+			name:     "named groups in any order",
+			pattern:  `^(?P<host>[^.]+)-(?P<name>[^.:]+)`,
+			endpoint: "org1-peer0:7051",
+			expected: Node{Name: "peer0", Host: "org1"},
+		},
+		{
+			name:     "without groups",
+			pattern:  `:7051$`,
+			endpoint: "peer0.org1.example.com:7051",
+			expected: Node{Name: "peer0.org1.example.com:7051"},
+		},
+		{
+			name:     "not matching",
+			endpoint: "localhost:7051",
+			message:  "Discovered peer localhost:7051 of Org1MSP does not match",
+		},
+		{
+			name:     "invalid pattern",
+			pattern:  `(?P<name>`,
+			endpoint: "peer-0.peer.org1.example.com:7051",
+			message:  "missing closing )",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := defaultNaming.merge(Naming{EndpointPattern: test.pattern})
+			node, err := n.node("Org1MSP", test.endpoint)
+			assertError(t, err, test.message)
+			if test.message != "" {
+				return
+			}
+			test.expected.MSPID = "Org1MSP"
+			test.expected.Endpoint = test.endpoint
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("unexpected node %+v, want %+v", node, test.expected)
+			}
+		})
+	}
+}
+
+func TestNamingAddress(t *testing.T) {
+	node := Node{Name: "peer-0", MSPID: "Org1MSP", Host: "org1.example.com", Endpoint: "peer-0.peer.org1.example.com:7051"}
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		message  string
+	}{
+		{name: "peer", template: defaultNaming.PeerAddress, expected: "peer-0.peer.org1.example.com:7051"},
+		{name: "endorser", template: defaultNaming.EndorserAddress, expected: "peer.org1.example.com:7051"},
+		{name: "lifecycle", template: defaultNaming.LifecycleAddress, expected: "http://lifecycle.org1.example.com:8090"},
+		{name: "crypto config", template: defaultNaming.CryptoConfig, expected: "/artifacts/crypto-config/peer-0"},
+		{name: "msp and endpoint", template: "https://{{.MSPID}}/{{.Endpoint}}", expected: "https://Org1MSP/peer-0.peer.org1.example.com:7051"},
+		{name: "constant", template: "peer.example.com:7051", expected: "peer.example.com:7051"},
+		{name: "unknown field", template: "{{.Port}}", message: "Failed to address peer-0.peer.org1.example.com:7051"},
+		{name: "invalid template", template: "{{.Name", message: "unclosed action"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			address, err := defaultNaming.address(test.template, node)
+			assertError(t, err, test.message)
+			if address != test.expected {
+				t.Errorf("expected address %q, got %q", test.expected, address)
+			}
+		})
+	}
+}
+
+func TestLeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    NamingConfig
+		endpoints map[string][]string
+		expected  []string
+	}{
+		{
+			name: "leader",
+			endpoints: map[string][]string{
+				"Org1MSP": {"peer-1.peer.org1.example.com:7051", "peer-0.peer.org1.example.com:7051", "peer-2.peer.org1.example.com:7051"},
+			},
+			expected: []string{"peer-0.peer.org1.example.com:7051"},
+		},
+		{
+			name: "lowest endpoint without leader",
+			endpoints: map[string][]string{
+				"Org1MSP": {"peer-2.peer.org1.example.com:7051", "peer-1.peer.org1.example.com:7051", "peer-3.peer.org1.example.com:7051"},
+			},
+			expected: []string{"peer-1.peer.org1.example.com:7051"},
+		},
+		{
+			name:   "msp leader over default leader",
+			config: NamingConfig{Default: Naming{Leader: "peer-1"}, MSPs: map[string]Naming{"Org2MSP": {Leader: "peer-2"}}},
+			endpoints: map[string][]string{
+				"Org1MSP": {"peer-0.peer.org1.example.com:7051", "peer-1.peer.org1.example.com:7051", "peer-2.peer.org1.example.com:7051"},
+				"Org2MSP": {"peer-0.peer.org2.example.com:7051", "peer-1.peer.org2.example.com:7051", "peer-2.peer.org2.example.com:7051"},
+			},
+			expected: []string{"peer-1.peer.org1.example.com:7051", "peer-2.peer.org2.example.com:7051"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previous := naming
+			defer func() { naming = previous }()
+			naming = &test.config
+
+			lifecycle := Lifecycle{}
+			for _, mspID := range []string{"Org1MSP", "Org2MSP"} {
+				for _, endpoint := range test.endpoints[mspID] {
+					node, err := NewNode(mspID, endpoint)
+					if err != nil {
+						t.Fatal(err)
+					}
+					lifecycle.Nodes = append(lifecycle.Nodes, node)
+				}
+			}
+
+			var leaders []string
+			for _, node := range lifecycle.leaders() {
+				leaders = append(leaders, node.Endpoint)
+			}
+			if !reflect.DeepEqual(leaders, test.expected) {
+				t.Errorf("unexpected leaders %v, want %v", leaders, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadNamingConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{name: "valid", config: `{"default": {"leader": "peer0"}, "msps": {"Org2MSP": {"endpoint_pattern": "^(?P<name>peer\\d+)\\.(?P<host>[^:]+)"}}}`},
+		{name: "empty", config: `{}`},
+		{name: "invalid json", config: `{"default": `, message: "Invalid naming config"},
+		{name: "invalid default", config: `{"default": {"peer_address": "{{.Name"}}`, message: "Invalid default naming"},
+		{name: "invalid msp pattern", config: `{"msps": {"Org2MSP": {"endpoint_pattern": "(?P<name>"}}}`, message: "Invalid naming of Org2MSP: invalid endpoint pattern"},
+		{name: "invalid msp template", config: `{"msps": {"Org2MSP": {"lifecycle_address": "{{.Host"}}}`, message: "Invalid naming of Org2MSP"},
+	}
+
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, "naming.json")
+			if err := ioutil.WriteFile(path, []byte(test.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadNamingConfig(path)
+			assertError(t, err, test.message)
+		})
+	}
+}
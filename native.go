@@ -0,0 +1,567 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	lb "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	lifecycleName = "_lifecycle"
+	csccName      = "cscc"
+
+	// maxMessageSize allows chaincode packages up to 100MB to be sent and received.
+	maxMessageSize = 100 * 1024 * 1024
+
+	// commitWaitTimeout defines how long to wait for the validation of a transaction, same as the peer cli.
+	commitWaitTimeout = 30 * time.Second
+)
+
+// EndorsementError is returned if a peer does not endorse a proposal.
+type EndorsementError struct {
+	Peer    string
+	Status  int32
+	Message string
+}
+
+func (e *EndorsementError) Error() string {
+	return fmt.Sprintf("%v failed to endorse proposal with status %v: %v", e.Peer, e.Status, e.Message)
+}
+
+// BroadcastError is returned if the orderer does not accept a transaction.
+type BroadcastError struct {
+	Status  common.Status
+	Message string
+}
+
+func (e *BroadcastError) Error() string {
+	return fmt.Sprintf("Orderer rejected transaction with status %v: %v", e.Status, e.Message)
+}
+
+// NativeBackend performs the lifecycle operations by invoking the _lifecycle system chaincode over grpc.
+type NativeBackend struct{}
+
+// Install installs the chaincode package on the target peer, signed by the msp of the target.
+func (n *NativeBackend) Install(ctx context.Context, target Target, pkg []byte) (string, error) {
+	var result lb.InstallChaincodeResult
+	if err := n.query(ctx, target, "", "InstallChaincode", &lb.InstallChaincodeArgs{ChaincodeInstallPackage: pkg}, &result); err != nil {
+		return "", err
+	}
+	return result.PackageId, nil
+}
+
+// QueryInstalled lists the installed chaincodes on the target.
+func (n *NativeBackend) QueryInstalled(ctx context.Context, target Target) ([]InstalledChaincode, error) {
+	var result lb.QueryInstalledChaincodesResult
+	if err := n.query(ctx, target, "", "QueryInstalledChaincodes", &lb.QueryInstalledChaincodesArgs{}, &result); err != nil {
+		return nil, err
+	}
+
+	var installed []InstalledChaincode
+	for _, chaincode := range result.InstalledChaincodes {
+		references := make(map[string]ChaincodeReferences)
+		for channel, refs := range chaincode.References {
+			var chaincodes []ChaincodeReference
+			for _, ref := range refs.Chaincodes {
+				chaincodes = append(chaincodes, ChaincodeReference{Name: ref.Name, Version: ref.Version})
+			}
+			references[channel] = ChaincodeReferences{Chaincodes: chaincodes}
+		}
+		installed = append(installed, InstalledChaincode{
+			PackageID:  chaincode.PackageId,
+			Label:      chaincode.Label,
+			References: references,
+		})
+	}
+	return installed, nil
+}
+
+// ApproveForMyOrg approves the chaincode definition for the local msp and waits until the local peer validated it.
+func (n *NativeBackend) ApproveForMyOrg(ctx context.Context, channel, name string, sequence int, packageID string, definition ChaincodeDefinition) error {
+	validationParameter, err := definition.ValidationParameter()
+	if err != nil {
+		return err
+	}
+	collections, err := definition.CollectionConfigPackage()
+	if err != nil {
+		return err
+	}
+
+	args := &lb.ApproveChaincodeDefinitionForMyOrgArgs{
+		Name:                name,
+		Version:             definition.Version,
+		Sequence:            int64(sequence),
+		EndorsementPlugin:   definition.EndorsementPlugin,
+		ValidationPlugin:    definition.ValidationPlugin,
+		ValidationParameter: validationParameter,
+		InitRequired:        definition.InitRequired,
+		Collections:         collections,
+		Source: &lb.ChaincodeSource{
+			Type: &lb.ChaincodeSource_LocalPackage{
+				LocalPackage: &lb.ChaincodeSource_Local{PackageId: packageID},
+			},
+		},
+	}
+
+	input, err := lifecycleInput("ApproveChaincodeDefinitionForMyOrg", args)
+	if err != nil {
+		return err
+	}
+	// like the peer cli, the approval waits until it has been validated by the local peer.
+	_, err = n.submitAndWait(ctx, channel, lifecycleName, input, []Target{localTarget()})
+	return err
+}
+
+// CheckCommitReadiness returns the approvals of the chaincode definition.
+func (n *NativeBackend) CheckCommitReadiness(ctx context.Context, channel, name string, sequence int, definition ChaincodeDefinition) (map[string]bool, error) {
+	validationParameter, err := definition.ValidationParameter()
+	if err != nil {
+		return nil, err
+	}
+	collections, err := definition.CollectionConfigPackage()
+	if err != nil {
+		return nil, err
+	}
+
+	args := &lb.CheckCommitReadinessArgs{
+		Name:                name,
+		Version:             definition.Version,
+		Sequence:            int64(sequence),
+		EndorsementPlugin:   definition.EndorsementPlugin,
+		ValidationPlugin:    definition.ValidationPlugin,
+		ValidationParameter: validationParameter,
+		InitRequired:        definition.InitRequired,
+		Collections:         collections,
+	}
+
+	var result lb.CheckCommitReadinessResult
+	if err := n.query(ctx, localTarget(), channel, "CheckCommitReadiness", args, &result); err != nil {
+		return nil, err
+	}
+	return result.Approvals, nil
+}
+
+// Commit commits the chaincode definition using the given targets as endorsers and waits for its validation.
+func (n *NativeBackend) Commit(ctx context.Context, channel, name string, sequence int, definition ChaincodeDefinition, targets []Target) ([]TxStatus, error) {
+	validationParameter, err := definition.ValidationParameter()
+	if err != nil {
+		return nil, err
+	}
+	collections, err := definition.CollectionConfigPackage()
+	if err != nil {
+		return nil, err
+	}
+
+	args := &lb.CommitChaincodeDefinitionArgs{
+		Name:                name,
+		Version:             definition.Version,
+		Sequence:            int64(sequence),
+		EndorsementPlugin:   definition.EndorsementPlugin,
+		ValidationPlugin:    definition.ValidationPlugin,
+		ValidationParameter: validationParameter,
+		InitRequired:        definition.InitRequired,
+		Collections:         collections,
+	}
+
+	input, err := lifecycleInput("CommitChaincodeDefinition", args)
+	if err != nil {
+		return nil, err
+	}
+	return n.submitAndWait(ctx, channel, lifecycleName, input, targets)
+}
+
+// Init invokes the init function of the committed chaincode on the targets and waits for its validation.
+func (n *NativeBackend) Init(ctx context.Context, channel, name, function string, args []string, targets []Target) ([]TxStatus, error) {
+	input := &peer.ChaincodeInput{Args: [][]byte{[]byte(function)}, IsInit: true}
+	for _, arg := range args {
+		input.Args = append(input.Args, []byte(arg))
+	}
+	return n.submitAndWait(ctx, channel, name, input, targets)
+}
+
+// QueryCommitted returns the committed chaincode definition.
+func (n *NativeBackend) QueryCommitted(ctx context.Context, channel, name string) (QueryCommitted, error) {
+	var committed QueryCommitted
+	var result lb.QueryChaincodeDefinitionResult
+	if err := n.query(ctx, localTarget(), channel, "QueryChaincodeDefinition", &lb.QueryChaincodeDefinitionArgs{Name: name}, &result); err != nil {
+		if e, ok := err.(*EndorsementError); ok && notDefined(name, e.Message) {
+			return committed, &NotDefinedError{Chaincode: name}
+		}
+		return committed, err
+	}
+
+	committed.Sequence = int(result.Sequence)
+	committed.Version = result.Version
+	return committed, nil
+}
+
+// GetInstalledPackage downloads the installed chaincode package from the target.
+func (n *NativeBackend) GetInstalledPackage(ctx context.Context, target Target, packageID string) ([]byte, error) {
+	var result lb.GetInstalledChaincodePackageResult
+	if err := n.query(ctx, target, "", "GetInstalledChaincodePackage", &lb.GetInstalledChaincodePackageArgs{PackageId: packageID}, &result); err != nil {
+		return nil, err
+	}
+	return result.ChaincodeInstallPackage, nil
+}
+
+// GetConfigBlock queries the latest config block of the channel from the configuration system chaincode of the local
+// peer.
+func (n *NativeBackend) GetConfigBlock(ctx context.Context, channel string) (*common.Block, error) {
+	input := &peer.ChaincodeInput{Args: [][]byte{[]byte("GetConfigBlock"), []byte(channel)}}
+	response, err := n.evaluate(ctx, localTarget(), "", csccName, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var block common.Block
+	if err := proto.Unmarshal(response.Response.Payload, &block); err != nil {
+		return nil, fmt.Errorf("Invalid config block of %v: %v", channel, err)
+	}
+	return &block, nil
+}
+
+// query evaluates a _lifecycle function on the target and unmarshals the result.
+func (n *NativeBackend) query(ctx context.Context, target Target, channel, function string, args, result proto.Message) error {
+	input, err := lifecycleInput(function, args)
+	if err != nil {
+		return err
+	}
+
+	response, err := n.evaluate(ctx, target, channel, lifecycleName, input)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(response.Response.Payload, result)
+}
+
+// evaluate endorses the chaincode input on the target without submitting the transaction.
+func (n *NativeBackend) evaluate(ctx context.Context, target Target, channel, chaincode string, input *peer.ChaincodeInput) (*peer.ProposalResponse, error) {
+	mspConfigPath := target.MSPConfigPath
+	if mspConfigPath == "" {
+		mspConfigPath = os.Getenv("CORE_PEER_MSPCONFIGPATH")
+	}
+
+	signer, err := NewSigner(os.Getenv("CORE_PEER_LOCALMSPID"), mspConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := newProposal(signer, channel, chaincode, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.endorse(ctx, proposal, target)
+}
+
+// submitAndWait endorses the chaincode input on the targets, sends the transaction to the orderer and waits until it
+// has been validated by every target.
+func (n *NativeBackend) submitAndWait(ctx context.Context, channel, chaincode string, input *peer.ChaincodeInput, targets []Target) ([]TxStatus, error) {
+	proposal, envelope, err := n.prepare(ctx, channel, chaincode, input, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commitWaitTimeout)
+	defer cancel()
+
+	// the deliver streams are opened before broadcasting, so that the block of the transaction cannot be missed.
+	var watchers []*txWatcher
+	defer func() {
+		for _, watcher := range watchers {
+			watcher.close()
+		}
+	}()
+	for _, target := range targets {
+		watcher, err := watch(ctx, proposal.signer, channel, target)
+		if err != nil {
+			return nil, err
+		}
+		watchers = append(watchers, watcher)
+	}
+
+	if err := n.broadcast(ctx, envelope); err != nil {
+		return nil, err
+	}
+
+	var statuses []TxStatus
+	for _, watcher := range watchers {
+		status, err := watcher.wait(proposal.TxID)
+		if err != nil {
+			return statuses, err
+		}
+		statuses = append(statuses, status)
+		if status.Code != peer.TxValidationCode_VALID.String() {
+			return statuses, &ValidationError{TxID: status.TxID, Peer: status.Peer, Code: status.Code}
+		}
+	}
+	return statuses, nil
+}
+
+// prepare endorses the chaincode input on the targets and assembles the signed transaction.
+func (n *NativeBackend) prepare(ctx context.Context, channel, chaincode string, input *peer.ChaincodeInput, targets []Target) (*Proposal, *common.Envelope, error) {
+	signer, err := NewSigner(os.Getenv("CORE_PEER_LOCALMSPID"), os.Getenv("CORE_PEER_MSPCONFIGPATH"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proposal, err := newProposal(signer, channel, chaincode, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var responses []*peer.ProposalResponse
+	for _, target := range targets {
+		response, err := n.endorse(ctx, proposal, target)
+		if err != nil {
+			return nil, nil, err
+		}
+		responses = append(responses, response)
+	}
+
+	envelope, err := proposal.transaction(responses)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proposal, envelope, nil
+}
+
+func (n *NativeBackend) endorse(ctx context.Context, proposal *Proposal, target Target) (*peer.ProposalResponse, error) {
+	conn, err := dial(ctx, target.Address, target.TLSRootCert)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	response, err := peer.NewEndorserClient(conn).ProcessProposal(ctx, proposal.Signed)
+	if err != nil {
+		return nil, err
+	}
+	if response.Response == nil || response.Response.Status < 200 || response.Response.Status >= 400 {
+		e := &EndorsementError{Peer: target.Address}
+		if response.Response != nil {
+			e.Status = response.Response.Status
+			e.Message = response.Response.Message
+		}
+		return nil, e
+	}
+	return response, nil
+}
+
+func (n *NativeBackend) broadcast(ctx context.Context, envelope *common.Envelope) error {
+	conn, err := dial(ctx, os.Getenv("ORDERER_ADDRESS"), os.Getenv("ORDERER_CA"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := orderer.NewAtomicBroadcastClient(conn).Broadcast(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.CloseSend()
+
+	if err := stream.Send(envelope); err != nil {
+		return err
+	}
+
+	response, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if response.Status != common.Status_SUCCESS {
+		return &BroadcastError{Status: response.Status, Message: response.Info}
+	}
+	return nil
+}
+
+// Proposal is a signed chaincode proposal.
+type Proposal struct {
+	TxID     string
+	Proposal *peer.Proposal
+	Signed   *peer.SignedProposal
+
+	signer *Signer
+}
+
+// lifecycleInput returns the input invoking the function of the _lifecycle chaincode with the marshalled args.
+func lifecycleInput(function string, args proto.Message) (*peer.ChaincodeInput, error) {
+	argsBytes, err := proto.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	return &peer.ChaincodeInput{Args: [][]byte{[]byte(function), argsBytes}}, nil
+}
+
+// newProposal creates a signed proposal invoking the chaincode with the given input.
+func newProposal(signer *Signer, channel, chaincode string, input *peer.ChaincodeInput) (*Proposal, error) {
+	creator, err := signer.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(append(nonce, creator...))
+	txID := hex.EncodeToString(digest[:])
+
+	extension, err := proto.Marshal(&peer.ChaincodeHeaderExtension{ChaincodeId: &peer.ChaincodeID{Name: chaincode}})
+	if err != nil {
+		return nil, err
+	}
+
+	channelHeader, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: channel,
+		TxId:      txID,
+		Timestamp: ptypes.TimestampNow(),
+		Extension: extension,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signatureHeader, err := proto.Marshal(&common.SignatureHeader{Creator: creator, Nonce: nonce})
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := proto.Marshal(&common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader})
+	if err != nil {
+		return nil, err
+	}
+
+	invocation, err := proto.Marshal(&peer.ChaincodeInvocationSpec{
+		ChaincodeSpec: &peer.ChaincodeSpec{
+			ChaincodeId: &peer.ChaincodeID{Name: chaincode},
+			Input:       input,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := proto.Marshal(&peer.ChaincodeProposalPayload{Input: invocation})
+	if err != nil {
+		return nil, err
+	}
+
+	proposal := &peer.Proposal{Header: header, Payload: payload}
+	proposalBytes, err := proto.Marshal(proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signer.Sign(proposalBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Proposal{
+		TxID:     txID,
+		Proposal: proposal,
+		Signed:   &peer.SignedProposal{ProposalBytes: proposalBytes, Signature: signature},
+		signer:   signer,
+	}, nil
+}
+
+// transaction assembles the signed transaction envelope from the proposal responses.
+func (p *Proposal) transaction(responses []*peer.ProposalResponse) (*common.Envelope, error) {
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("No proposal responses received")
+	}
+
+	var endorsements []*peer.Endorsement
+	for _, response := range responses {
+		if !bytes.Equal(response.Payload, responses[0].Payload) {
+			return nil, fmt.Errorf("Proposal responses do not match")
+		}
+		endorsements = append(endorsements, response.Endorsement)
+	}
+
+	var header common.Header
+	if err := proto.Unmarshal(p.Proposal.Header, &header); err != nil {
+		return nil, err
+	}
+
+	actionPayload, err := proto.Marshal(&peer.ChaincodeActionPayload{
+		ChaincodeProposalPayload: p.Proposal.Payload,
+		Action: &peer.ChaincodeEndorsedAction{
+			ProposalResponsePayload: responses[0].Payload,
+			Endorsements:            endorsements,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := proto.Marshal(&peer.Transaction{
+		Actions: []*peer.TransactionAction{{Header: header.SignatureHeader, Payload: actionPayload}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := proto.Marshal(&common.Payload{Header: &header, Data: transaction})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := p.signer.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &common.Envelope{Payload: payload, Signature: signature}, nil
+}
+
+// dial opens a tls secured grpc connection. Client certificates are used if CORE_PEER_TLS_CLIENTAUTHREQUIRED is set.
+func dial(ctx context.Context, address, rootCertFile string) (*grpc.ClientConn, error) {
+	rootCert, err := ioutil.ReadFile(rootCertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(rootCert) {
+		return nil, fmt.Errorf("Failed to parse root cert %v", rootCertFile)
+	}
+	config := &tls.Config{RootCAs: pool}
+
+	if os.Getenv("CORE_PEER_TLS_CLIENTAUTHREQUIRED") == "true" {
+		cert, err := tls.LoadX509KeyPair(os.Getenv("CORE_PEER_TLS_CLIENTCERT_FILE"), os.Getenv("CORE_PEER_TLS_CLIENTKEY_FILE"))
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return grpc.DialContext(ctx, address,
+		grpc.WithTransportCredentials(credentials.NewTLS(config)),
+		grpc.WithBlock(),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize), grpc.MaxCallSendMsgSize(maxMessageSize)),
+	)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// fakeNetwork plays the peers and the orderer of the native backend. Broadcast transactions are delivered to the
+// filtered deliver streams with the configured validation code.
+type fakeNetwork struct {
+	mu        sync.Mutex
+	code      peer.TxValidationCode
+	silent    bool // whether transactions are never delivered
+	watchers  int  // the number of deliver streams expected before a broadcast
+	order     []string
+	deadlines []time.Time
+	streams   []chan *peer.DeliverResponse
+	opened    chan struct{}
+}
+
+type fakePeer struct{ *fakeNetwork }
+
+type fakeOrderer struct{ *fakeNetwork }
+
+func (p fakePeer) ProcessProposal(ctx context.Context, signed *peer.SignedProposal) (*peer.ProposalResponse, error) {
+	return &peer.ProposalResponse{
+		Response:    &peer.Response{Status: 200},
+		Payload:     []byte("result"),
+		Endorsement: &peer.Endorsement{Endorser: []byte("peer"), Signature: []byte("signature")},
+	}, nil
+}
+
+func (p fakePeer) Deliver(peer.Deliver_DeliverServer) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (p fakePeer) DeliverWithPrivateData(peer.Deliver_DeliverWithPrivateDataServer) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (p fakePeer) DeliverFiltered(stream peer.Deliver_DeliverFilteredServer) error {
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+
+	blocks := make(chan *peer.DeliverResponse, 1)
+	p.mu.Lock()
+	p.order = append(p.order, "deliver")
+	deadline, _ := stream.Context().Deadline()
+	p.deadlines = append(p.deadlines, deadline)
+	p.streams = append(p.streams, blocks)
+	p.opened <- struct{}{}
+	p.mu.Unlock()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case block := <-blocks:
+			if err := stream.Send(block); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (o fakeOrderer) Broadcast(stream orderer.AtomicBroadcast_BroadcastServer) error {
+	envelope, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	var payload common.Payload
+	if err := proto.Unmarshal(envelope.Payload, &payload); err != nil {
+		return err
+	}
+	var header common.ChannelHeader
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, &header); err != nil {
+		return err
+	}
+
+	// a watcher opened after the broadcast would never be opened, as the client waits for the response first.
+	for i := 0; i < o.watchers; i++ {
+		select {
+		case <-o.opened:
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	o.mu.Lock()
+	o.order = append(o.order, "broadcast")
+	streams := o.streams
+	o.mu.Unlock()
+
+	if err := stream.Send(&orderer.BroadcastResponse{Status: common.Status_SUCCESS}); err != nil {
+		return err
+	}
+	if !o.silent {
+		block := &peer.DeliverResponse{Type: &peer.DeliverResponse_FilteredBlock{FilteredBlock: &peer.FilteredBlock{
+			ChannelId:            header.ChannelId,
+			Number:               5,
+			FilteredTransactions: []*peer.FilteredTransaction{{Txid: header.TxId, TxValidationCode: o.code}},
+		}}}
+		for _, blocks := range streams {
+			blocks <- block
+		}
+	}
+
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (o fakeOrderer) Deliver(orderer.AtomicBroadcast_DeliverServer) error {
+	return fmt.Errorf("not implemented")
+}
+
+// serveFakeNetwork starts the fake network on a tls secured local port and configures the environment of the native
+// backend to use it. The returned function stops the network and restores the environment.
+func serveFakeNetwork(t *testing.T, network *fakeNetwork) (Target, func()) {
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := newTestCA(t, "tlsca.example.com")
+	serverCert := serverCertificate(t, ca)
+	caFile := filepath.Join(dir, "tlsca.pem")
+	if err := ioutil.WriteFile(caFile, ca.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, key := newTestCA(t, "ca.org1.example.com").issue(t, "Admin@org1.example.com", "admin")
+	msp := filepath.Join(dir, "msp")
+	for name, content := range map[string][]byte{"keystore/priv_sk": key, "signcerts/cert.pem": cert} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(msp, name)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(msp, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network.opened = make(chan struct{}, 16)
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{serverCert}})))
+	peer.RegisterEndorserServer(server, fakePeer{network})
+	peer.RegisterDeliverServer(server, fakePeer{network})
+	orderer.RegisterAtomicBroadcastServer(server, fakeOrderer{network})
+	go server.Serve(listener)
+
+	address := listener.Addr().String()
+	env := map[string]string{
+		"CORE_PEER_LOCALMSPID":             "Org1MSP",
+		"CORE_PEER_MSPCONFIGPATH":          msp,
+		"CORE_PEER_TLS_CLIENTAUTHREQUIRED": "false",
+		"ORDERER_ADDRESS":                  address,
+		"ORDERER_CA":                       caFile,
+	}
+	previous := make(map[string]string)
+	for name, value := range env {
+		previous[name] = os.Getenv(name)
+		os.Setenv(name, value)
+	}
+
+	return Target{Address: address, TLSRootCert: caFile}, func() {
+		server.Stop()
+		for name, value := range previous {
+			os.Setenv(name, value)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// serverCertificate issues the tls certificate of the fake network for 127.0.0.1.
+func serverCertificate(t *testing.T, ca *testCA) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{raw}, PrivateKey: key}
+}
+
+func TestSubmitAndWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    peer.TxValidationCode
+		message string
+	}{
+		{name: "valid", code: peer.TxValidationCode_VALID},
+		{name: "invalidated", code: peer.TxValidationCode_MVCC_READ_CONFLICT, message: "invalidated with status MVCC_READ_CONFLICT"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network := &fakeNetwork{code: test.code, watchers: 2}
+			target, stop := serveFakeNetwork(t, network)
+			defer stop()
+
+			started := time.Now()
+			statuses, err := (&NativeBackend{}).Init(context.Background(), "mychannel", "mycc", "Init", nil, []Target{target, target})
+			assertError(t, err, test.message)
+
+			network.mu.Lock()
+			defer network.mu.Unlock()
+			if fmt.Sprint(network.order) != "[deliver deliver broadcast]" {
+				t.Errorf("expected the deliver streams to be opened before broadcasting, got %v", network.order)
+			}
+			for _, deadline := range network.deadlines {
+				if wait := deadline.Sub(started); wait <= commitWaitTimeout-5*time.Second || wait > commitWaitTimeout+time.Second {
+					t.Errorf("expected to wait up to %v for the transaction, got %v", commitWaitTimeout, wait)
+				}
+			}
+
+			if test.message != "" {
+				e, ok := err.(*ValidationError)
+				if !ok || e.Code != test.code.String() || e.Peer != target.Address || e.TxID == "" {
+					t.Errorf("expected a validation error, got %#v", err)
+				}
+				return
+			}
+			if len(statuses) != 2 {
+				t.Fatalf("expected a status per target, got %v", statuses)
+			}
+			for _, status := range statuses {
+				if status.Code != "VALID" || status.Block != 5 || status.Peer != target.Address || status.TxID == "" {
+					t.Errorf("unexpected status %+v", status)
+				}
+			}
+		})
+	}
+}
+
+func TestSubmitAndWaitTimesOut(t *testing.T) {
+	network := &fakeNetwork{silent: true, watchers: 1}
+	target, stop := serveFakeNetwork(t, network)
+	defer stop()
+
+	// the commit wait is bounded by the context of the caller as well.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := (&NativeBackend{}).Init(ctx, "mychannel", "mycc", "Init", nil, []Target{target})
+	assertError(t, err, "Failed to wait for transaction")
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// File represents a file within an archive.
+type File struct {
+	Name string
+	Mode int64
+	Body []byte
+}
+
+// PackageID computes the package id of a chaincode package the same way the peer does.
+func PackageID(label string, pkg []byte) string {
+	hash := sha256.Sum256(pkg)
+	return fmt.Sprintf("%v:%x", label, hash)
+}
+
+// PackageIDHandler returns the package id the chaincode would be installed with, without installing it. Accepts the
+// same optional body as the deploy endpoint.
+func PackageIDHandler(w http.ResponseWriter, req *http.Request) {
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
+
+	request, err := parseDeployRequest(w, req)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if err := request.apply(&lifecycle); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	pkg, err := lifecycle.Package()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(w, "%v", PackageID(lifecycle.Chaincode, pkg))
+}
+
+// Package builds the chaincode install package of the lifecycle. The same input always yields the same bytes.
+func (l *Lifecycle) Package() ([]byte, error) {
+	if l.prebuilt != nil {
+		return l.prebuilt, nil
+	}
+
+	metadata, err := l.metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	if l.Source != nil {
+		if files, err = l.Source.files(); err != nil {
+			return nil, err
+		}
+	} else {
+		connection, err := json.Marshal(l.Connection.UserData(l.Chaincode))
+		if err != nil {
+			return nil, err
+		}
+		files = []File{{Name: "connection.json", Body: connection}}
+	}
+
+	code, err := buildArchive(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildArchive([]File{
+		{Name: "metadata.json", Body: metadata},
+		{Name: "code.tar.gz", Body: code},
+	})
+}
+
+func (l *Lifecycle) metadata() ([]byte, error) {
+	metadata := PackageMetadata{
+		Label: l.Chaincode,
+		Path:  "",
+		Type:  "external",
+	}
+	if l.Source != nil {
+		metadata.Path = l.Source.Path
+		metadata.Type = l.Source.Type
+	}
+	return json.Marshal(metadata)
+}
+
+// buildArchive writes the files as gzipped tar. Files are sorted by name and written without timestamps or owners, so
+// that the archive only depends on the names, modes and contents of the files.
+func buildArchive(files []File) ([]byte, error) {
+	sorted := make([]File, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, file := range sorted {
+		mode := file.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Name,
+			Mode:     mode,
+			Size:     int64(len(file.Body)),
+			ModTime:  time.Unix(0, 0),
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(file.Body); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maxArchiveSize limits the total size of the files read from an archive.
+const maxArchiveSize = 4 * maxUploadSize
+
+// readArchive reads the regular files of a gzipped tar. Names are cleaned and must not leave the archive. The total
+// size of the files is limited by maxArchiveSize.
+func readArchive(archive []byte) ([]File, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	var files []File
+	var size int64
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			// directories are implied by the file names, links are not supported.
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("Illegal file name %v in archive", header.Name)
+		}
+
+		// the header size cannot be trusted, hence the read is limited to the remaining size.
+		body, err := ioutil.ReadAll(io.LimitReader(tr, maxArchiveSize-size+1))
+		if err != nil {
+			return nil, err
+		}
+		if size += int64(len(body)); size > maxArchiveSize {
+			return nil, fmt.Errorf("Archive exceeds %v bytes", maxArchiveSize)
+		}
+		files = append(files, File{Name: name, Mode: header.Mode & 0755, Body: body})
+	}
+}
+
+// readDir reads the regular files within the directory using slash separated names relative to the directory.
+func readDir(dir string) ([]File, error) {
+	var files []File
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && file != dir && strings.HasPrefix(info.Name(), ".") {
+			// skip hidden folders like .git
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Name: filepath.ToSlash(name), Mode: int64(info.Mode().Perm() & 0755), Body: body})
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+// zeros reads an endless stream of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// compressed builds a gzipped tar containing files of the given sizes filled with zeros.
+func compressed(t *testing.T, sizes ...int64) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for i, size := range sizes {
+		header := &tar.Header{Typeflag: tar.TypeReg, Name: string(rune('a' + i)), Mode: 0644, Size: size}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.CopyN(tw, zeros{}, size); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadArchiveLimitsSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		sizes   []int64
+		message string
+	}{
+		{name: "small files", sizes: []int64{1 << 10, 1 << 20}},
+		{name: "single large file", sizes: []int64{maxArchiveSize + 1}, message: "Archive exceeds"},
+		{name: "many files", sizes: []int64{maxArchiveSize / 2, maxArchiveSize / 2, 1}, message: "Archive exceeds"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := readArchive(compressed(t, test.sizes...))
+			assertError(t, err, test.message)
+			if err == nil && len(files) != len(test.sizes) {
+				t.Errorf("expected %v files, got %v", len(test.sizes), len(files))
+			}
+		})
+	}
+}
+
+func TestBuildArchiveIsDeterministic(t *testing.T) {
+	files := []File{
+		{Name: "src/main.go", Body: []byte("package main\n")},
+		{Name: "src/go.mod", Body: []byte("module mycc\n")},
+		{Name: "bin/run", Mode: 0755, Body: []byte("#!/bin/sh\n")},
+	}
+	reversed := []File{files[2], files[1], files[0]}
+
+	first, err := buildArchive(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := buildArchive(reversed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("expected the same archive regardless of the file order")
+	}
+
+	read, err := readArchive(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 3 || read[0].Name != "bin/run" || read[0].Mode != 0755 || read[2].Mode != 0644 {
+		t.Errorf("unexpected files %v", read)
+	}
+}
+
+func TestPackageIsDeterministicAcrossRuns(t *testing.T) {
+	lifecycle := Lifecycle{Chaincode: "mycc", Connection: Connection{Address: "mycc.example.com", Port: 9999}}
+	pkg, err := lifecycle.Package()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := lifecycle.Package()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pkg, again) {
+		t.Error("expected the same package for the same input")
+	}
+
+	// pinned so that a change to the archive layout, which would change the package id of every deployed chaincode,
+	// does not go unnoticed.
+	expected := "mycc:679a04fa0152ffa18bcafa09455882a4bc391c2ce4218fbfc2fd8320399cde71"
+	if id := PackageID(lifecycle.Chaincode, pkg); id != expected {
+		t.Errorf("expected package id %v, got %v", expected, id)
+	}
+}
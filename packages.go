@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+)
+
+// labelRegexp matches the labels accepted by the peer.
+var labelRegexp = regexp.MustCompile(`^[[:alnum:]][[:alnum:]_.+-]*$`)
+
+// validatePackage checks that the package contains a valid metadata.json and code.tar.gz and returns its metadata.
+func validatePackage(pkg []byte) (PackageMetadata, error) {
+	var metadata PackageMetadata
+	files, err := readArchive(pkg)
+	if err != nil {
+		return metadata, fmt.Errorf("Invalid package: %v", err)
+	}
+
+	var hasMetadata, hasCode bool
+	for _, file := range files {
+		switch file.Name {
+		case "metadata.json":
+			if err := json.Unmarshal(file.Body, &metadata); err != nil {
+				return metadata, fmt.Errorf("Invalid metadata.json: %v", err)
+			}
+			hasMetadata = true
+		case "code.tar.gz":
+			if _, err := readArchive(file.Body); err != nil {
+				return metadata, fmt.Errorf("Invalid code.tar.gz: %v", err)
+			}
+			hasCode = true
+		}
+	}
+
+	if !hasMetadata {
+		return metadata, fmt.Errorf("Package is missing metadata.json")
+	}
+	if !hasCode {
+		return metadata, fmt.Errorf("Package is missing code.tar.gz")
+	}
+	if !labelRegexp.MatchString(metadata.Label) {
+		return metadata, fmt.Errorf("Invalid label %v", metadata.Label)
+	}
+	if metadata.Type == "" {
+		return metadata, fmt.Errorf("Missing chaincode type")
+	}
+	return metadata, nil
+}
+
+// UploadPackage installs a pre-built chaincode package uploaded as multipart form file package and returns its
+// package id.
+func UploadPackage(w http.ResponseWriter, req *http.Request) {
+	req.Body = http.MaxBytesReader(w, req.Body, maxRequestSize)
+	if err := req.ParseMultipartForm(maxUploadSize); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := req.FormFile("package")
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	pkg, err := ioutil.ReadAll(file)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	metadata, err := validatePackage(pkg)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	lifecycle := NewLifecycle(req.Context(), map[string]string{"chaincode": metadata.Label})
+	lifecycle.prebuilt = pkg
+	if err := lifecycle.install(); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully installed uploaded package %v", lifecycle.CCID)
+	fmt.Fprintf(w, "%v", lifecycle.CCID)
+}
+
+// DownloadPackage returns the chaincode package installed on the local peer with the given package id.
+func DownloadPackage(w http.ResponseWriter, req *http.Request) {
+	packageID := mux.Vars(req)["packageID"]
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
+
+	pkg, err := lifecycle.getInstalledPackage(packageID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if pkg == nil {
+		logger.Warnf("Package %v could not be found", packageID)
+		http.Error(w, fmt.Sprintf("Package %v could not be found", packageID), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", packageID+".tar.gz"))
+	w.Write(pkg)
+}
+
+// getInstalledPackage returns the package installed on the local peer or nil if it is not installed.
+func (l *Lifecycle) getInstalledPackage(packageID string) ([]byte, error) {
+	installed, err := l.Backend.QueryInstalled(l.context(), localTarget())
+	if err != nil {
+		return nil, err
+	}
+	for _, chaincode := range installed {
+		if chaincode.PackageID == packageID {
+			return l.Backend.GetInstalledPackage(l.context(), localTarget(), packageID)
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// principalRegexp matches principals like 'Org1MSP.member'.
+var principalRegexp = regexp.MustCompile(`^([[:alnum:].-]+)[.](admin|member|client|peer|orderer)$`)
+
+var roles = map[string]msp.MSPRole_MSPRoleType{
+	"member":  msp.MSPRole_MEMBER,
+	"admin":   msp.MSPRole_ADMIN,
+	"client":  msp.MSPRole_CLIENT,
+	"peer":    msp.MSPRole_PEER,
+	"orderer": msp.MSPRole_ORDERER,
+}
+
+// policyExpression is a node of a parsed signature policy, either a gate or a principal.
+type policyExpression struct {
+	principal string
+	n         int
+	args      []*policyExpression
+}
+
+// ParseSignaturePolicy parses a signature policy like AND('Org1MSP.member', OR('Org2MSP.peer', 'Org3MSP.peer')). The
+// resulting envelope is identical to the one built by the peer cli, so that approvals of both match.
+func ParseSignaturePolicy(policy string) (*common.SignaturePolicyEnvelope, error) {
+	expression, err := parsePolicyExpression(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []*msp.MSPPrincipal
+	rule, err := expression.build(&identities)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signature policy %v: %v", policy, err)
+	}
+	return &common.SignaturePolicyEnvelope{Version: 0, Rule: rule, Identities: identities}, nil
+}
+
+// parsePolicyExpression parses the signature policy into its expression tree.
+func parsePolicyExpression(policy string) (*policyExpression, error) {
+	p := &policyParser{tokens: tokenizePolicy(policy)}
+	expression, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signature policy %v: %v", policy, err)
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("Invalid signature policy %v: unexpected %v", policy, p.tokens[p.pos])
+	}
+	if expression.principal != "" {
+		return nil, fmt.Errorf("Invalid signature policy %v: expected a gate", policy)
+	}
+	return expression, nil
+}
+
+// PolicyMSPs returns the msp ids referenced by the principals of a signature policy.
+func PolicyMSPs(policy string) ([]string, error) {
+	envelope, err := ParseSignaturePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var mspIDs []string
+	seen := make(map[string]bool)
+	for _, identity := range envelope.Identities {
+		var role msp.MSPRole
+		if err := proto.Unmarshal(identity.Principal, &role); err != nil {
+			return nil, err
+		}
+		if !seen[role.MspIdentifier] {
+			seen[role.MspIdentifier] = true
+			mspIDs = append(mspIDs, role.MspIdentifier)
+		}
+	}
+	return mspIDs, nil
+}
+
+// build converts the expression into a signature policy. Nested gates are built before the principals of a gate, which
+// results in the same order of identities as the expression evaluation of the peer cli.
+func (e *policyExpression) build(identities *[]*msp.MSPPrincipal) (*common.SignaturePolicy, error) {
+	if e.n < 0 || e.n > len(e.args)+1 {
+		return nil, fmt.Errorf("invalid t-out-of-n predicate, t %v, n %v", e.n, len(e.args))
+	}
+
+	rules := make([]*common.SignaturePolicy, len(e.args))
+	for i, arg := range e.args {
+		if arg.principal != "" {
+			continue
+		}
+		rule, err := arg.build(identities)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = rule
+	}
+
+	for i, arg := range e.args {
+		if arg.principal == "" {
+			continue
+		}
+		match := principalRegexp.FindStringSubmatch(arg.principal)
+		if match == nil {
+			return nil, fmt.Errorf("invalid principal %v", arg.principal)
+		}
+		principal, err := proto.Marshal(&msp.MSPRole{MspIdentifier: match[1], Role: roles[match[2]]})
+		if err != nil {
+			return nil, err
+		}
+		*identities = append(*identities, &msp.MSPPrincipal{
+			PrincipalClassification: msp.MSPPrincipal_ROLE,
+			Principal:               principal,
+		})
+		rules[i] = &common.SignaturePolicy{
+			Type: &common.SignaturePolicy_SignedBy{SignedBy: int32(len(*identities) - 1)},
+		}
+	}
+
+	return &common.SignaturePolicy{
+		Type: &common.SignaturePolicy_NOutOf_{
+			NOutOf: &common.SignaturePolicy_NOutOf{N: int32(e.n), Rules: rules},
+		},
+	}, nil
+}
+
+// satisfied evaluates the expression, a principal is satisfied if its msp is. Roles are not taken into account.
+func (e *policyExpression) satisfied(msp func(mspID string) bool) bool {
+	if e.principal != "" {
+		match := principalRegexp.FindStringSubmatch(e.principal)
+		return match != nil && msp(match[1])
+	}
+
+	count := 0
+	for _, arg := range e.args {
+		if arg.satisfied(msp) {
+			count++
+		}
+	}
+	return count >= e.n
+}
+
+type policyParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *policyParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token
+}
+
+func (p *policyParser) expect(token string) error {
+	if next := p.next(); next != token {
+		return fmt.Errorf("expected %v but got %q", token, next)
+	}
+	return nil
+}
+
+func (p *policyParser) parse() (*policyExpression, error) {
+	token := p.next()
+	if token == "" {
+		return nil, fmt.Errorf("unexpected end of policy")
+	}
+	if strings.HasPrefix(token, "'") || strings.HasPrefix(token, `"`) {
+		if len(token) < 2 || token[len(token)-1] != token[0] {
+			return nil, fmt.Errorf("unterminated quote %v", token)
+		}
+		return &policyExpression{principal: token[1 : len(token)-1]}, nil
+	}
+
+	gate := strings.ToLower(token)
+	if gate != "and" && gate != "or" && gate != "outof" {
+		return nil, fmt.Errorf("unrecognized token %v", token)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	expression := &policyExpression{}
+	if gate == "outof" {
+		n, err := strconv.Atoi(p.next())
+		if err != nil {
+			return nil, fmt.Errorf("expected a number in OutOf")
+		}
+		expression.n = n
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		arg, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		expression.args = append(expression.args, arg)
+
+		token := p.next()
+		if token == ")" {
+			break
+		}
+		if token != "," {
+			return nil, fmt.Errorf("expected , or ) but got %q", token)
+		}
+	}
+
+	switch gate {
+	case "and":
+		expression.n = len(expression.args)
+	case "or":
+		expression.n = 1
+	}
+	return expression, nil
+}
+
+// tokenizePolicy splits the policy into gates, numbers, quoted principals and punctuation.
+func tokenizePolicy(policy string) []string {
+	var tokens []string
+	runes := []rune(policy)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+		case r == '(' || r == ')' || r == ',':
+			tokens = append(tokens, string(r))
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				// unterminated quotes are reported by the parser.
+				tokens = append(tokens, string(runes[i:]))
+				return tokens
+			}
+			tokens = append(tokens, string(runes[i:end+1]))
+			i = end
+		default:
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) {
+				end++
+			}
+			if end == i {
+				end++
+			}
+			tokens = append(tokens, string(runes[i:end]))
+			i = end - 1
+		}
+	}
+	return tokens
+}
+
+// ApplicationPolicy builds the application policy from either a signature policy or a channel config policy
+// reference. Returns nil if neither is given.
+func ApplicationPolicy(signaturePolicy, channelConfigPolicy string) (*peer.ApplicationPolicy, error) {
+	switch {
+	case signaturePolicy != "" && channelConfigPolicy != "":
+		return nil, fmt.Errorf("Signature policy and channel config policy must not both be given")
+	case signaturePolicy != "":
+		envelope, err := ParseSignaturePolicy(signaturePolicy)
+		if err != nil {
+			return nil, err
+		}
+		return &peer.ApplicationPolicy{Type: &peer.ApplicationPolicy_SignaturePolicy{SignaturePolicy: envelope}}, nil
+	case channelConfigPolicy != "":
+		return &peer.ApplicationPolicy{Type: &peer.ApplicationPolicy_ChannelConfigPolicyReference{ChannelConfigPolicyReference: channelConfigPolicy}}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// FormatSignaturePolicy formats the signature policy envelope as OutOf gates over its principals, the inverse of
+// ParseSignaturePolicy. Principals of organizational units and identities are formatted as members of their msp.
+func FormatSignaturePolicy(envelope *common.SignaturePolicyEnvelope) (string, error) {
+	principals := make([]string, len(envelope.Identities))
+	for i, identity := range envelope.Identities {
+		principal, err := formatPrincipal(identity)
+		if err != nil {
+			return "", err
+		}
+		principals[i] = principal
+	}
+	return formatRule(envelope.Rule, principals)
+}
+
+func formatRule(rule *common.SignaturePolicy, principals []string) (string, error) {
+	switch r := rule.GetType().(type) {
+	case *common.SignaturePolicy_SignedBy:
+		if r.SignedBy < 0 || int(r.SignedBy) >= len(principals) {
+			return "", fmt.Errorf("Invalid signature policy: unknown identity %v", r.SignedBy)
+		}
+		return principals[r.SignedBy], nil
+	case *common.SignaturePolicy_NOutOf_:
+		args := []string{strconv.Itoa(int(r.NOutOf.N))}
+		for _, sub := range r.NOutOf.Rules {
+			arg, err := formatRule(sub, principals)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, arg)
+		}
+		return fmt.Sprintf("OutOf(%v)", strings.Join(args, ", ")), nil
+	}
+	return "", fmt.Errorf("Invalid signature policy: missing rule")
+}
+
+func formatPrincipal(identity *msp.MSPPrincipal) (string, error) {
+	switch identity.PrincipalClassification {
+	case msp.MSPPrincipal_ROLE:
+		var role msp.MSPRole
+		if err := proto.Unmarshal(identity.Principal, &role); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("'%v.%v'", role.MspIdentifier, strings.ToLower(role.Role.String())), nil
+	case msp.MSPPrincipal_ORGANIZATION_UNIT:
+		var unit msp.OrganizationUnit
+		if err := proto.Unmarshal(identity.Principal, &unit); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("'%v.member'", unit.MspIdentifier), nil
+	case msp.MSPPrincipal_IDENTITY:
+		var serialized msp.SerializedIdentity
+		if err := proto.Unmarshal(identity.Principal, &serialized); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("'%v.member'", serialized.Mspid), nil
+	}
+	return "", fmt.Errorf("Unsupported principal classification %v", identity.PrincipalClassification)
+}
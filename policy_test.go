@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestParseSignaturePolicyMatchesPeerCLI(t *testing.T) {
+	// envelopes marshalled by cauthdsl.FromString of fabric v2.0.1, which the peer cli uses for --signature-policy.
+	tests := []struct {
+		policy   string
+		envelope string
+	}{
+		{
+			policy:   "OR('Org1MSP.member','Org2MSP.member')",
+			envelope: "120c120a080112020800120208011a0b12090a074f7267314d53501a0b12090a074f7267324d5350",
+		},
+		{
+			policy:   "AND('Org1MSP.peer', OR('Org2MSP.peer', 'Org3MSP.peer'))",
+			envelope: "12161214080212020802120c120a080112020800120208011a0d120b0a074f7267324d535010031a0d120b0a074f7267334d535010031a0d120b0a074f7267314d53501003",
+		},
+		{
+			policy:   "OutOf(2, 'Org1MSP.admin', 'Org2MSP.client', 'Org3MSP.orderer')",
+			envelope: "1210120e08021202080012020801120208021a0d120b0a074f7267314d535010011a0d120b0a074f7267324d535010021a0d120b0a074f7267334d53501004",
+		},
+		{
+			policy:   "OR(AND('Org1MSP.member', 'Org2MSP.member'), 'Org3MSP.member')",
+			envelope: "121612140801120c120a08021202080012020801120208021a0b12090a074f7267314d53501a0b12090a074f7267324d53501a0b12090a074f7267334d5350",
+		},
+	}
+
+	for _, test := range tests {
+		envelope, err := ParseSignaturePolicy(test.policy)
+		if err != nil {
+			t.Fatalf("%v: %v", test.policy, err)
+		}
+		bytes, err := proto.Marshal(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if envelope := hex.EncodeToString(bytes); envelope != test.envelope {
+			t.Errorf("%v: expected %v, got %v", test.policy, test.envelope, envelope)
+		}
+	}
+}
+
+func TestParseSignaturePolicyRejectsInvalidPolicies(t *testing.T) {
+	for _, policy := range []string{
+		"",
+		"'Org1MSP.member'",
+		"AND('Org1MSP.member'",
+		"OR('Org1MSP.member', 'Org2MSP.owner')",
+		"OutOf(3, 'Org1MSP.member')",
+		"OR('Org1MSP.member') 'Org2MSP.member'",
+		"AND('",
+		`OR("`,
+		"'",
+		"OR('Org1MSP.member', 'Org2MSP.member",
+	} {
+		if _, err := ParseSignaturePolicy(policy); err == nil {
+			t.Errorf("%v: expected an invalid policy", policy)
+		}
+	}
+}
+
+func TestFormatSignaturePolicy(t *testing.T) {
+	for _, policy := range []string{
+		"OR('Org1MSP.member','Org2MSP.member')",
+		"AND('Org1MSP.peer', OR('Org2MSP.peer', 'Org3MSP.peer'))",
+		"OutOf(2, 'Org1MSP.admin', 'Org2MSP.client', 'Org3MSP.orderer')",
+	} {
+		envelope, err := ParseSignaturePolicy(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted, err := FormatSignaturePolicy(envelope)
+		if err != nil {
+			t.Fatalf("%v: %v", policy, err)
+		}
+		reparsed, err := ParseSignaturePolicy(formatted)
+		if err != nil {
+			t.Fatalf("%v: %v", formatted, err)
+		}
+		if !proto.Equal(envelope, reparsed) {
+			t.Errorf("%v: formatted as %v, which results in a different envelope", policy, formatted)
+		}
+	}
+}
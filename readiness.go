@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// commitReadinessInterval defines how often the commit readiness is checked.
+const commitReadinessInterval = 2 * time.Second
+
+// defaultCommitReadinessTimeout defines how long to wait for the approvals if COMMIT_READINESS_TIMEOUT is not set.
+const defaultCommitReadinessTimeout = 5 * time.Minute
+
+// defaultManualApprovalTimeout defines how long to wait for approvals parked for manual consent if
+// MANUAL_APPROVAL_TIMEOUT is not set.
+const defaultManualApprovalTimeout = 24 * time.Hour
+
+// timeout returns the duration configured by the environment variable or the default.
+func timeout(name string, defaultTimeout time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %v %v: %v", strings.ToLower(strings.Replace(name, "_", " ", -1)), value, err)
+	}
+	return timeout, nil
+}
+
+// WaitForCommitReadiness polls the commit readiness of the chaincode definition until the approvals satisfy the
+// lifecycle endorsement policy of the channel, an organization rejects its parked approval or the timeout expires.
+// While approvals are parked for manual consent, the job waits until MANUAL_APPROVAL_TIMEOUT instead. The timeout
+// restarts once a parked approval has been accepted.
+func (l *Lifecycle) WaitForCommitReadiness() error {
+	readinessTimeout, err := timeout("COMMIT_READINESS_TIMEOUT", defaultCommitReadinessTimeout)
+	if err != nil {
+		return err
+	}
+	manualTimeout, err := timeout("MANUAL_APPROVAL_TIMEOUT", defaultManualApprovalTimeout)
+	if err != nil {
+		return err
+	}
+	policy, err := l.lifecycleEndorsementPolicy()
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	deadline := started.Add(readinessTimeout)
+	parked := l.job.parkedApprovals()
+	var missing []string
+	for {
+		if err := l.job.rejection(); err != nil {
+			return err
+		}
+		if still := l.job.parkedApprovals(); still < parked {
+			// an organization accepted its parked approval, which still has to arrive.
+			deadline = time.Now().Add(readinessTimeout)
+			parked = still
+		}
+
+		ctx, cancel := context.WithTimeout(l.context(), readinessTimeout)
+		approvals, err := l.Backend.CheckCommitReadiness(ctx, l.Channel, l.Chaincode, l.Sequence, l.Definition)
+		cancel()
+		if err == nil {
+			var ready bool
+			if ready, err = lifecycleEndorsementSatisfied(policy, approvals); err != nil {
+				return err
+			}
+			if ready {
+				return nil
+			}
+
+			if still := missingApprovals(approvals); strings.Join(still, ",") != strings.Join(missing, ",") {
+				missing = still
+				l.job.Infof("Waiting for approvals of %v", strings.Join(missing, ", "))
+			}
+		} else {
+			logger.Warnf("Failed to check commit readiness of %v on %v: %v", l.Chaincode, l.Channel, err)
+		}
+
+		expires := deadline
+		if parked > 0 {
+			expires = started.Add(manualTimeout)
+		}
+		if time.Now().After(expires) {
+			if len(missing) == 0 && err != nil {
+				return fmt.Errorf("Timed out checking commit readiness: %v", err)
+			}
+			return fmt.Errorf("Timed out waiting for approvals of %v", strings.Join(missing, ", "))
+		}
+
+		select {
+		case <-l.context().Done():
+			return l.context().Err()
+		case <-time.After(commitReadinessInterval):
+		}
+	}
+}
+
+// lifecycleEndorsementPolicy returns the policy the approvals are checked against. The LifecycleEndorsement policy of
+// the channel config is used unless LIFECYCLE_ENDORSEMENT_POLICY overrides it.
+func (l *Lifecycle) lifecycleEndorsementPolicy() (string, error) {
+	if policy := os.Getenv("LIFECYCLE_ENDORSEMENT_POLICY"); policy != "" {
+		return policy, nil
+	}
+
+	block, err := l.Backend.GetConfigBlock(l.context(), l.Channel)
+	if err != nil {
+		return "", fmt.Errorf("Failed to fetch the config of %v: %v", l.Channel, err)
+	}
+	policy, err := lifecycleEndorsement(block)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read the lifecycle endorsement policy of %v: %v", l.Channel, err)
+	}
+	logger.Infof("Checking approvals of %v on %v against the lifecycle endorsement policy %v", l.Chaincode, l.Channel, policy)
+	return policy, nil
+}
+
+// lifecycleEndorsement reads the LifecycleEndorsement policy of the application group from the config block. Implicit
+// meta policies are returned as their rule over the organizations, signature policies as OutOf gates.
+func lifecycleEndorsement(block *common.Block) (string, error) {
+	if block.GetData() == nil || len(block.Data.Data) == 0 {
+		return "", fmt.Errorf("Missing config transaction")
+	}
+	var envelope common.Envelope
+	if err := proto.Unmarshal(block.Data.Data[0], &envelope); err != nil {
+		return "", err
+	}
+	var payload common.Payload
+	if err := proto.Unmarshal(envelope.Payload, &payload); err != nil {
+		return "", err
+	}
+	var config common.ConfigEnvelope
+	if err := proto.Unmarshal(payload.Data, &config); err != nil {
+		return "", err
+	}
+
+	application, ok := config.GetConfig().GetChannelGroup().GetGroups()["Application"]
+	if !ok {
+		return "", fmt.Errorf("Missing application group")
+	}
+	policy, ok := application.Policies["LifecycleEndorsement"]
+	if !ok || policy.Policy == nil {
+		return "", fmt.Errorf("Missing LifecycleEndorsement policy")
+	}
+
+	switch common.Policy_PolicyType(policy.Policy.Type) {
+	case common.Policy_IMPLICIT_META:
+		var meta common.ImplicitMetaPolicy
+		if err := proto.Unmarshal(policy.Policy.Value, &meta); err != nil {
+			return "", err
+		}
+		return meta.Rule.String(), nil
+	case common.Policy_SIGNATURE:
+		var signature common.SignaturePolicyEnvelope
+		if err := proto.Unmarshal(policy.Policy.Value, &signature); err != nil {
+			return "", err
+		}
+		return FormatSignaturePolicy(&signature)
+	}
+	return "", fmt.Errorf("Unsupported policy type %v", policy.Policy.Type)
+}
+
+// lifecycleEndorsementSatisfied checks the approvals against the lifecycle endorsement policy, which is either MAJORITY
+// (default), ALL, ANY or a signature policy over the msps of the channel. Implicit meta policies are evaluated over the
+// approvals of the organizations, their sub policies are not taken into account.
+func lifecycleEndorsementSatisfied(policy string, approvals map[string]bool) (bool, error) {
+	if len(approvals) == 0 {
+		// an empty readiness does not list the msps of the channel, hence it never satisfies the policy.
+		return false, nil
+	}
+
+	approved := 0
+	for _, ok := range approvals {
+		if ok {
+			approved++
+		}
+	}
+
+	switch strings.ToUpper(policy) {
+	case "", "MAJORITY":
+		return approved > len(approvals)/2, nil
+	case "ALL":
+		return approved == len(approvals), nil
+	case "ANY":
+		return approved > 0, nil
+	}
+
+	expression, err := parsePolicyExpression(policy)
+	if err != nil {
+		return false, fmt.Errorf("Invalid lifecycle endorsement policy: %v", err)
+	}
+	return expression.satisfied(func(mspID string) bool { return approvals[mspID] }), nil
+}
+
+// missingApprovals returns the sorted msps which have not approved yet.
+func missingApprovals(approvals map[string]bool) []string {
+	var missing []string
+	for mspID, ok := range approvals {
+		if !ok {
+			missing = append(missing, mspID)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
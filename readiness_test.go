@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+func TestLifecycleEndorsementSatisfied(t *testing.T) {
+	tests := []struct {
+		policy    string
+		approvals map[string]bool
+		ready     bool
+	}{
+		{policy: "", approvals: map[string]bool{"Org1MSP": true, "Org2MSP": true, "Org3MSP": false}, ready: true},
+		{policy: "MAJORITY", approvals: map[string]bool{"Org1MSP": true, "Org2MSP": false}, ready: false},
+		{policy: "ALL", approvals: map[string]bool{"Org1MSP": true, "Org2MSP": true}, ready: true},
+		{policy: "ALL", approvals: map[string]bool{"Org1MSP": true, "Org2MSP": false}, ready: false},
+		{policy: "any", approvals: map[string]bool{"Org1MSP": false, "Org2MSP": true}, ready: true},
+		{policy: "AND('Org1MSP.peer', OR('Org2MSP.peer', 'Org3MSP.peer'))", approvals: map[string]bool{"Org1MSP": true, "Org3MSP": true}, ready: true},
+		{policy: "AND('Org1MSP.peer', 'Org2MSP.peer')", approvals: map[string]bool{"Org1MSP": true, "Org2MSP": false}, ready: false},
+		{policy: "ALL", approvals: map[string]bool{}, ready: false},
+		{policy: "MAJORITY", approvals: nil, ready: false},
+		{policy: "OUTOF(0, 'Org1MSP.peer')", approvals: map[string]bool{}, ready: false},
+	}
+
+	for _, test := range tests {
+		ready, err := lifecycleEndorsementSatisfied(test.policy, test.approvals)
+		if err != nil {
+			t.Fatalf("%v: %v", test.policy, err)
+		}
+		if ready != test.ready {
+			t.Errorf("%v with %v: expected ready %v, got %v", test.policy, test.approvals, test.ready, ready)
+		}
+	}
+
+	if _, err := lifecycleEndorsementSatisfied("AND(", map[string]bool{"Org1MSP": true}); err == nil {
+		t.Error("expected an invalid policy")
+	}
+}
+
+// configBlock builds a config block whose application group carries the LifecycleEndorsement policy.
+func configBlock(t *testing.T, policy *common.Policy) *common.Block {
+	marshal := func(message proto.Message) []byte {
+		data, err := proto.Marshal(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	config := &common.ConfigEnvelope{Config: &common.Config{ChannelGroup: &common.ConfigGroup{
+		Groups: map[string]*common.ConfigGroup{
+			"Application": {Policies: map[string]*common.ConfigPolicy{"LifecycleEndorsement": {Policy: policy}}},
+		},
+	}}}
+	envelope := &common.Envelope{Payload: marshal(&common.Payload{Data: marshal(config)})}
+	return &common.Block{Data: &common.BlockData{Data: [][]byte{marshal(envelope)}}}
+}
+
+func TestLifecycleEndorsement(t *testing.T) {
+	signature, err := ParseSignaturePolicy("AND('Org1MSP.peer', OR('Org2MSP.peer', 'Org3MSP.peer'))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureValue, err := proto.Marshal(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metaValue, err := proto.Marshal(&common.ImplicitMetaPolicy{SubPolicy: "Endorsement", Rule: common.ImplicitMetaPolicy_ALL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		block   *common.Block
+		policy  string
+		message string
+	}{
+		{
+			name:   "implicit meta",
+			block:  configBlock(t, &common.Policy{Type: int32(common.Policy_IMPLICIT_META), Value: metaValue}),
+			policy: "ALL",
+		},
+		{
+			name:   "signature",
+			block:  configBlock(t, &common.Policy{Type: int32(common.Policy_SIGNATURE), Value: signatureValue}),
+			policy: "OutOf(2, 'Org1MSP.peer', OutOf(1, 'Org2MSP.peer', 'Org3MSP.peer'))",
+		},
+		{
+			name:    "missing policy",
+			block:   configBlock(t, nil),
+			message: "Missing LifecycleEndorsement policy",
+		},
+		{name: "empty block", block: &common.Block{}, message: "Missing config transaction"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := lifecycleEndorsement(test.block)
+			assertError(t, err, test.message)
+			if policy != test.policy {
+				t.Errorf("expected policy %q, got %q", test.policy, policy)
+			}
+		})
+	}
+}
+
+func TestLifecycleEndorsementPolicyIsReadFromTheChannel(t *testing.T) {
+	metaValue, err := proto.Marshal(&common.ImplicitMetaPolicy{SubPolicy: "Endorsement", Rule: common.ImplicitMetaPolicy_ANY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := proto.Marshal(configBlock(t, &common.Policy{Type: int32(common.Policy_IMPLICIT_META), Value: metaValue}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := (&FakeRunner{}).Do(func(argv []string) {
+		if err := ioutil.WriteFile(argv[4], block, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}, "peer", "channel", "fetch", "config")
+	lifecycle, restore := newTestLifecycle(t, fake, nil)
+	defer restore()
+	previous := os.Getenv("LIFECYCLE_ENDORSEMENT_POLICY")
+	defer os.Setenv("LIFECYCLE_ENDORSEMENT_POLICY", previous)
+
+	os.Setenv("LIFECYCLE_ENDORSEMENT_POLICY", "")
+	policy, err := lifecycle.lifecycleEndorsementPolicy()
+	if err != nil || policy != "ANY" {
+		t.Errorf("expected the policy ANY of the channel, got %v %v", policy, err)
+	}
+	commands := fake.Commands("peer", "channel", "fetch", "config")
+	if len(commands) != 1 {
+		t.Fatalf("expected the config to be fetched once, got %v", commands)
+	}
+	assertCommand(t, commands[0][5:], []string{
+		"--channelID", "mychannel", "-o", "orderer.example.com:7050", "--tls", "--cafile", "/certs/orderer-ca.pem",
+	})
+
+	os.Setenv("LIFECYCLE_ENDORSEMENT_POLICY", "ALL")
+	if policy, err := lifecycle.lifecycleEndorsementPolicy(); err != nil || policy != "ALL" {
+		t.Errorf("expected the overriding policy ALL, got %v %v", policy, err)
+	}
+	if commands := fake.Commands("peer", "channel", "fetch"); len(commands) != 1 {
+		t.Errorf("expected the config not to be fetched with an override, got %v", commands)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultRemoteTimeout defines how long a request to the lifecycle service of another organization may take if
+// REMOTE_TIMEOUT is not set. Installs of source code are built by the remote peer within the request.
+const defaultRemoteTimeout = 10 * time.Minute
+
+// registry maps msp ids to the lifecycle services of their organizations.
+var registry = NewRegistry("")
+
+// RemoteEndpoint describes how the lifecycle service of an organization is reached.
+type RemoteEndpoint struct {
+	MSPID      string `json:"mspid"`
+	URL        string `json:"url"`
+	CACert     string `json:"ca_cert,omitempty"`      // PEM encoded ca bundle to verify the lifecycle service
+	CACertFile string `json:"ca_cert_file,omitempty"` // path to the ca bundle, only accepted from REGISTRY_CONFIG
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	Token      string `json:"token,omitempty"` // bearer token, must not be combined with username and password
+}
+
+// Registry is the registry of remote lifecycle services. Changes are written back to the configuration file if the
+// registry has been loaded from one.
+type Registry struct {
+	mu        sync.Mutex
+	path      string
+	endpoints map[string]RemoteEndpoint
+}
+
+// NewRegistry builds an empty registry persisted to path.
+func NewRegistry(path string) *Registry {
+	return &Registry{path: path, endpoints: make(map[string]RemoteEndpoint)}
+}
+
+// LoadRegistry reads the registry from the json file at path, which contains a list of endpoints. A missing file
+// results in an empty registry.
+func LoadRegistry(path string) (*Registry, error) {
+	r := NewRegistry(path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+
+	var endpoints []RemoteEndpoint
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return nil, fmt.Errorf("Invalid registry %v: %v", path, err)
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.CACertFile != "" {
+			caCert, err := ioutil.ReadFile(endpoint.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid registry %v: %v", path, err)
+			}
+			endpoint.CACert = string(caCert)
+			endpoint.CACertFile = ""
+		}
+		if err := endpoint.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid registry %v: %v", path, err)
+		}
+		r.endpoints[endpoint.MSPID] = endpoint
+	}
+	return r, nil
+}
+
+// Validate checks the endpoint and keeps only the certificates of the ca bundle. Ca bundle files are read by
+// LoadRegistry, references to files are rejected here so that callers of the api cannot read files of the server.
+func (e *RemoteEndpoint) Validate() error {
+	if e.MSPID == "" {
+		return fmt.Errorf("Missing msp id")
+	}
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return fmt.Errorf("Invalid url of %v: %v", e.MSPID, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("Invalid url of %v: %v", e.MSPID, e.URL)
+	}
+	e.URL = strings.TrimSuffix(e.URL, "/")
+
+	if e.Token != "" && (e.Username != "" || e.Password != "") {
+		return fmt.Errorf("Token and username of %v must not both be given", e.MSPID)
+	}
+
+	if e.CACertFile != "" {
+		return fmt.Errorf("Ca cert file of %v is only accepted from REGISTRY_CONFIG", e.MSPID)
+	}
+	if e.CACert != "" {
+		caCert, err := certificates([]byte(e.CACert))
+		if err != nil {
+			return fmt.Errorf("Invalid ca cert of %v: %v", e.MSPID, err)
+		}
+		e.CACert = string(caCert)
+	}
+	return nil
+}
+
+// certificates re-encodes the certificates of the PEM bundle, any other blocks like private keys are dropped.
+func certificates(bundle []byte) ([]byte, error) {
+	var certs []byte
+	for {
+		var block *pem.Block
+		if block, bundle = pem.Decode(bundle); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return certs, nil
+}
+
+// redacted returns the endpoint without its credentials.
+func (e RemoteEndpoint) redacted() RemoteEndpoint {
+	if e.Password != "" {
+		e.Password = "********"
+	}
+	if e.Token != "" {
+		e.Token = "********"
+	}
+	return e
+}
+
+// client returns the http client trusting the ca bundle of the endpoint. The tls client certificate of the peer is
+// presented if configured, so that the remote lifecycle service can authenticate the organization. Requests time out
+// after REMOTE_TIMEOUT.
+func (e RemoteEndpoint) client() (*http.Client, error) {
+	remoteTimeout, err := timeout("REMOTE_TIMEOUT", defaultRemoteTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{}
+	if e.CACert != "" {
+		config.RootCAs = x509.NewCertPool()
+		config.RootCAs.AppendCertsFromPEM([]byte(e.CACert))
+	}
+	if certFile, keyFile := os.Getenv("CORE_PEER_TLS_CLIENTCERT_FILE"), os.Getenv("CORE_PEER_TLS_CLIENTKEY_FILE"); certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true},
+		Timeout:   remoteTimeout,
+	}, nil
+}
+
+// Get returns the registered endpoint of the msp.
+func (r *Registry) Get(mspID string) (RemoteEndpoint, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	endpoint, ok := r.endpoints[mspID]
+	return endpoint, ok
+}
+
+// List returns all registered endpoints sorted by msp id.
+func (r *Registry) List() []RemoteEndpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.list()
+}
+
+func (r *Registry) list() []RemoteEndpoint {
+	endpoints := []RemoteEndpoint{}
+	for _, endpoint := range r.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].MSPID < endpoints[j].MSPID })
+	return endpoints
+}
+
+// Put validates and registers the endpoint.
+func (r *Registry) Put(endpoint RemoteEndpoint) error {
+	if err := endpoint.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, ok := r.endpoints[endpoint.MSPID]
+	r.endpoints[endpoint.MSPID] = endpoint
+	if err := r.save(); err != nil {
+		if ok {
+			r.endpoints[endpoint.MSPID] = previous
+		} else {
+			delete(r.endpoints, endpoint.MSPID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the endpoint of the msp. Returns false if it has not been registered.
+func (r *Registry) Delete(mspID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, ok := r.endpoints[mspID]
+	if !ok {
+		return false, nil
+	}
+	delete(r.endpoints, mspID)
+	if err := r.save(); err != nil {
+		r.endpoints[mspID] = previous
+		return true, err
+	}
+	return true, nil
+}
+
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// the registry contains credentials.
+	return ioutil.WriteFile(r.path, data, 0600)
+}
+
+// post sends the signed json body to the lifecycle service of the node's organization. Uses the registered endpoint
+// of the msp or falls back to the lifecycle address of the naming configuration.
+func (l *Lifecycle) post(node Node, path string, body []byte) (*http.Response, error) {
+	endpoint, ok := registry.Get(node.MSPID)
+	if !ok {
+		address, err := l.lifecycleURL(node)
+		if err != nil {
+			return nil, err
+		}
+		endpoint = RemoteEndpoint{MSPID: node.MSPID, URL: address}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(l.context())
+	req.Header.Set("Content-Type", "application/json")
+	if endpoint.Username != "" || endpoint.Password != "" {
+		req.SetBasicAuth(endpoint.Username, endpoint.Password)
+	}
+	if endpoint.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+endpoint.Token)
+	}
+	if err := signRequest(req, l.Channel, body); err != nil {
+		return nil, err
+	}
+
+	client, err := endpoint.client()
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// GetRegistry returns the registered lifecycle services without their credentials.
+func GetRegistry(w http.ResponseWriter, req *http.Request) {
+	endpoints := []RemoteEndpoint{}
+	for _, endpoint := range registry.List() {
+		endpoints = append(endpoints, endpoint.redacted())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(endpoints); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+	}
+}
+
+// PutRegistry registers the lifecycle service of the msp.
+func PutRegistry(w http.ResponseWriter, req *http.Request) {
+	var endpoint RemoteEndpoint
+	if err := decodeRequest(req, &endpoint); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+	endpoint.MSPID = mux.Vars(req)["mspid"]
+	if err := endpoint.Validate(); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if err := registry.Put(endpoint); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("Registered lifecycle service %v of %v", endpoint.URL, endpoint.MSPID)
+}
+
+// DeleteRegistry removes the lifecycle service of the msp, which is then addressed by the naming configuration.
+func DeleteRegistry(w http.ResponseWriter, req *http.Request) {
+	mspID := mux.Vars(req)["mspid"]
+	found, err := registry.Delete(mspID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		logger.Warnf("No lifecycle service registered for %v", mspID)
+		http.Error(w, fmt.Sprintf("No lifecycle service registered for %v", mspID), http.StatusNotFound)
+		return
+	}
+	logger.Infof("Removed lifecycle service of %v", mspID)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRemoteEndpointClientTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	previous := os.Getenv("REMOTE_TIMEOUT")
+	defer os.Setenv("REMOTE_TIMEOUT", previous)
+	os.Setenv("REMOTE_TIMEOUT", "50ms")
+
+	client, err := RemoteEndpoint{MSPID: "Org2MSP", URL: server.URL}.client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Timeout != 50*time.Millisecond {
+		t.Errorf("unexpected timeout %v", client.Timeout)
+	}
+	if _, err := client.Post(server.URL+"/install/mycc", "application/json", nil); err == nil {
+		t.Error("expected the request to time out")
+	}
+
+	os.Setenv("REMOTE_TIMEOUT", "soon")
+	if _, err := (RemoteEndpoint{}).client(); err == nil {
+		t.Error("expected an invalid timeout")
+	}
+}
+
+func TestRemoteEndpointKeepsOnlyCertificates(t *testing.T) {
+	ca := newTestCA(t, "ca.org2.example.com")
+	cert, key := ca.issue(t, "lifecycle.org2.example.com", "")
+	bundle := string(key) + string(ca.pem) + string(cert)
+
+	endpoint := RemoteEndpoint{MSPID: "Org2MSP", URL: "https://lifecycle.org2.example.com", CACert: bundle}
+	if err := endpoint.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(endpoint.CACert, "PRIVATE KEY") || endpoint.CACert != string(ca.pem)+string(cert) {
+		t.Errorf("unexpected ca cert %v", endpoint.CACert)
+	}
+
+	endpoint = RemoteEndpoint{MSPID: "Org2MSP", URL: "https://lifecycle.org2.example.com", CACert: string(key)}
+	assertError(t, endpoint.Validate(), "Invalid ca cert of Org2MSP")
+}
+
+func TestPutRegistryRejectsCACertFiles(t *testing.T) {
+	previous := registry
+	defer func() { registry = previous }()
+	registry = NewRegistry("")
+
+	body, err := json.Marshal(RemoteEndpoint{URL: "https://lifecycle.org2.example.com", CACertFile: "/etc/passwd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("PUT", "/registry/Org2MSP", bytes.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"mspid": "Org2MSP"})
+	w := httptest.NewRecorder()
+	PutRegistry(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "only accepted from REGISTRY_CONFIG") {
+		t.Errorf("unexpected response %v: %v", w.Code, w.Body.String())
+	}
+	if _, ok := registry.Get("Org2MSP"); ok {
+		t.Error("expected the endpoint not to be registered")
+	}
+}
+
+func TestLoadRegistryReadsCACertFiles(t *testing.T) {
+	ca := newTestCA(t, "ca.org2.example.com")
+	_, key := ca.issue(t, "lifecycle.org2.example.com", "")
+
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "org2-ca.pem")
+	if err := ioutil.WriteFile(caFile, append(key, ca.pem...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := json.Marshal([]RemoteEndpoint{{MSPID: "Org2MSP", URL: "https://lifecycle.org2.example.com", CACertFile: caFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "registry.json")
+	if err := ioutil.WriteFile(path, config, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, ok := loaded.Get("Org2MSP")
+	if !ok || endpoint.CACert != string(ca.pem) || endpoint.CACertFile != "" {
+		t.Errorf("unexpected endpoint %+v", endpoint)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// maxUploadSize limits the size of uploaded source archives held in memory.
+const maxUploadSize = 32 << 20
+
+// maxRequestSize limits the size of request bodies, which may carry an uploaded archive as form file or base64
+// encoded in json.
+const maxRequestSize = 2 * maxUploadSize
+
+// DeployRequest represents the optional json body accepted by the deploy and install endpoints.
+type DeployRequest struct {
+	Definition ChaincodeDefinition `json:"definition"`
+	Connection Connection          `json:"connection"`
+	Source     *Source             `json:"source,omitempty"`
+	Init       *InitRequest        `json:"init,omitempty"`
+}
+
+// decodeRequest decodes the json body of the request into v. An empty body leaves v untouched.
+func decodeRequest(req *http.Request, v interface{}) error {
+	if req.Body == nil {
+		return nil
+	}
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// parseDeployRequest parses either a json body or a multipart form. The multipart form may contain the json request
+// in the field request, and a source archive in the file source with its language in type and its import path in path.
+func parseDeployRequest(w http.ResponseWriter, req *http.Request) (DeployRequest, error) {
+	var request DeployRequest
+	if req.Body != nil {
+		req.Body = http.MaxBytesReader(w, req.Body, maxRequestSize)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+		return request, decodeRequest(req, &request)
+	}
+
+	if err := req.ParseMultipartForm(maxUploadSize); err != nil {
+		return request, err
+	}
+	if raw := req.FormValue("request"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &request); err != nil {
+			return request, err
+		}
+	}
+
+	file, _, err := req.FormFile("source")
+	if err == http.ErrMissingFile {
+		return request, nil
+	}
+	if err != nil {
+		return request, err
+	}
+	defer file.Close()
+
+	archive, err := ioutil.ReadAll(file)
+	if err != nil {
+		return request, err
+	}
+	request.Source = &Source{Type: req.FormValue("type"), Path: req.FormValue("path"), Archive: archive}
+	return request, nil
+}
+
+// apply applies the request to the lifecycle.
+func (r DeployRequest) apply(l *Lifecycle) error {
+	if err := r.Definition.Validate(); err != nil {
+		return err
+	}
+	l.Definition = r.Definition
+
+	if r.Connection.references() && l.Requester != l.MSPID {
+		// other organizations must not read files of the local lifecycle service into the package.
+		return fmt.Errorf("File references are only accepted from %v", l.MSPID)
+	}
+	if err := r.Connection.Resolve(); err != nil {
+		return err
+	}
+	l.Connection = r.Connection
+
+	if r.Source != nil {
+		if r.Source.Dir != "" && l.Requester != l.MSPID {
+			return fmt.Errorf("Source directories are only accepted from %v", l.MSPID)
+		}
+		if err := r.Source.Validate(); err != nil {
+			return err
+		}
+		if err := r.Source.load(); err != nil {
+			return err
+		}
+	}
+	l.Source = r.Source
+
+	if r.Init != nil {
+		if !r.Definition.InitRequired {
+			return fmt.Errorf("Init requires a definition with init_required")
+		}
+		if err := r.Init.Validate(); err != nil {
+			return err
+		}
+	}
+	l.Init = r.Init
+	return nil
+}
+
+// within checks that the path is located within the root directory once symbolic links are resolved.
+func within(root, name string) bool {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// request returns the request forwarded to the other organizations.
+func (l *Lifecycle) request() DeployRequest {
+	return DeployRequest{Definition: l.Definition, Connection: l.Connection, Source: l.Source}
+}
@@ -1,9 +1,7 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 )
 
@@ -13,26 +11,30 @@ type QueryCommitted struct {
 	Version  string `json:"version"`
 }
 
-// NextSequence calculates the next sequence number based on the committed chaincodes.
-func (l *Lifecycle) NextSequence() error {
-	command := []string{
-		"peer lifecycle chaincode querycommitted",
-		fmt.Sprintf("--channelID %v", l.Channel),
-		fmt.Sprintf("--name %v", l.Chaincode),
-		fmt.Sprintf("-o %v", os.Getenv("ORDERER_ADDRESS")),
-		fmt.Sprintf("--tls %v", "true"),
-		fmt.Sprintf("--cafile %v", os.Getenv("ORDERER_CA")),
-		fmt.Sprintf("--peerAddresses %v", os.Getenv("CORE_PEER_ADDRESS")),
-		fmt.Sprintf("--tlsRootCertFiles %v", os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE")),
-		"-O json",
-	}
+// NotDefinedError is returned if no chaincode definition has been committed for the chaincode.
+type NotDefinedError struct {
+	Chaincode string
+}
+
+func (e *NotDefinedError) Error() string {
+	return fmt.Sprintf("Chaincode %v is not defined", e.Chaincode)
+}
+
+// notDefined checks whether the peer rejected a query because the chaincode has not been committed yet.
+func notDefined(name, message string) bool {
+	return strings.Contains(message, fmt.Sprintf("namespace %v is not defined", name))
+}
 
-	response, err := l.execute(strings.Join(command, " "))
-	if err == nil {
-		var committed QueryCommitted
-		err = json.Unmarshal(response.Output.Bytes(), &committed)
-		l.Sequence = committed.Sequence + 1
+// NextSequence calculates the next sequence number based on the committed chaincodes. The requested sequence is kept
+// if the chaincode has not been committed yet.
+func (l *Lifecycle) NextSequence() error {
+	committed, err := l.Backend.QueryCommitted(l.context(), l.Channel, l.Chaincode)
+	if err != nil {
+		if _, ok := err.(*NotDefinedError); ok {
+			return nil
+		}
 		return err
 	}
+	l.Sequence = committed.Sequence + 1
 	return nil
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNextSequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		logs     string
+		err      error
+		sequence int
+		message  string
+	}{
+		{name: "committed", output: `{"sequence": 3, "version": "1.0"}`, sequence: 4},
+		{
+			name:     "not defined",
+			logs:     "Error: query failed with status: 500 - failed to invoke backing implementation of 'QueryChaincodeDefinition': namespace mycc is not defined",
+			err:      fmt.Errorf("exit status 1"),
+			sequence: 1,
+		},
+		{
+			name:    "unavailable peer",
+			logs:    "Error: failed to retrieve endorser client for querycommitted: endorser client failed to connect to peer-0.peer.org1.example.com:7051",
+			err:     fmt.Errorf("exit status 1"),
+			message: "exit status 1",
+		},
+		{name: "invalid output", output: "sequence 3", message: "Invalid committed definition of mycc"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := (&FakeRunner{}).On(test.output, test.logs, test.err, "peer", "lifecycle", "chaincode", "querycommitted")
+			lifecycle, restore := newTestLifecycle(t, fake, nil)
+			defer restore()
+
+			err := lifecycle.NextSequence()
+			assertError(t, err, test.message)
+			if err == nil && lifecycle.Sequence != test.sequence {
+				t.Errorf("expected sequence %v, got %v", test.sequence, lifecycle.Sequence)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultListenAddress is the address the lifecycle service listens on if LISTEN_ADDRESS is not set.
+const defaultListenAddress = ":8090"
+
+// certReloadInterval defines how often the tls certificate and client cas are checked for changes.
+const certReloadInterval = 10 * time.Second
+
+// Server serves the api either over http or over https with reloadable certificates.
+type Server struct {
+	*http.Server
+	certs *CertReloader
+}
+
+// NewServer builds the server configured by LISTEN_ADDRESS and the SERVER_TLS_* environment variables. The tls
+// certificate defaults to the tls certificate of the peer.
+func NewServer(handler http.Handler) (*Server, error) {
+	address := os.Getenv("LISTEN_ADDRESS")
+	if address == "" {
+		address = defaultListenAddress
+	}
+	server := &Server{Server: &http.Server{Addr: address, Handler: handler}}
+	if os.Getenv("SERVER_TLS_ENABLED") != "true" {
+		return server, nil
+	}
+
+	certFile, keyFile := os.Getenv("SERVER_TLS_CERT_FILE"), os.Getenv("SERVER_TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" {
+		certFile, keyFile = os.Getenv("CORE_PEER_TLS_CERT_FILE"), os.Getenv("CORE_PEER_TLS_KEY_FILE")
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("Missing tls certificate or key of the server")
+	}
+
+	var caFiles []string
+	for _, file := range strings.Split(os.Getenv("SERVER_TLS_CLIENT_CA_FILES"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			caFiles = append(caFiles, file)
+		}
+	}
+	clientAuth, err := clientAuthType(os.Getenv("SERVER_TLS_CLIENT_AUTH"), len(caFiles) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if server.certs, err = NewCertReloader(certFile, keyFile, caFiles, clientAuth); err != nil {
+		return nil, err
+	}
+	server.TLSConfig = server.certs.config()
+	return server, nil
+}
+
+// clientAuthType maps none (default), request or require to the tls client authentication. Requested client
+// certificates are verified against the client cas if configured, otherwise only by the organization authentication.
+func clientAuthType(value string, cas bool) (tls.ClientAuthType, error) {
+	switch value {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		if cas {
+			return tls.VerifyClientCertIfGiven, nil
+		}
+		return tls.RequestClientCert, nil
+	case "require":
+		if !cas {
+			return tls.NoClientCert, fmt.Errorf("Required client certificates need SERVER_TLS_CLIENT_CA_FILES")
+		}
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("Invalid client authentication %v", value)
+}
+
+// ListenAndServe serves the api and reloads the certificates on change.
+func (s *Server) ListenAndServe() error {
+	if s.certs == nil {
+		logger.Infof("Listening on %v", s.Addr)
+		return s.Server.ListenAndServe()
+	}
+
+	go s.certs.Watch(certReloadInterval)
+	logger.Infof("Listening on %v with tls", s.Addr)
+	return s.Server.ListenAndServeTLS("", "")
+}
+
+// CertReloader keeps the tls certificate and client cas of the server and reloads them if their files change.
+type CertReloader struct {
+	mu         sync.RWMutex
+	certFile   string
+	keyFile    string
+	caFiles    []string
+	clientAuth tls.ClientAuthType
+	cert       *tls.Certificate
+	clientCAs  *x509.CertPool
+	modified   time.Time
+}
+
+// NewCertReloader loads the certificate and the client cas.
+func NewCertReloader(certFile, keyFile string, caFiles []string, clientAuth tls.ClientAuthType) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, caFiles: caFiles, clientAuth: clientAuth}
+	modified, err := r.modTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modified); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// modTime returns the latest modification time of the certificate files.
+func (r *CertReloader) modTime() (time.Time, error) {
+	var modified time.Time
+	for _, file := range append([]string{r.certFile, r.keyFile}, r.caFiles...) {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modified, err
+		}
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	return modified, nil
+}
+
+func (r *CertReloader) load(modified time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("Failed to load tls certificate %v: %v", r.certFile, err)
+	}
+
+	var clientCAs *x509.CertPool
+	if len(r.caFiles) > 0 {
+		clientCAs = x509.NewCertPool()
+		for _, file := range r.caFiles {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if !clientCAs.AppendCertsFromPEM(data) {
+				return fmt.Errorf("Invalid client ca %v", file)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modified = modified
+	return nil
+}
+
+// Watch polls the certificate files and reloads them on change.
+func (r *CertReloader) Watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		r.reload()
+	}
+}
+
+// reload loads the certificate files if they have changed. The previous certificates are kept if the changed files
+// cannot be loaded, e.g. while they are being written.
+func (r *CertReloader) reload() {
+	modified, err := r.modTime()
+	if err != nil {
+		logger.Warnf("Failed to check tls certificate %v: %v", r.certFile, err)
+		return
+	}
+
+	r.mu.RLock()
+	changed := !modified.Equal(r.modified)
+	r.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := r.load(modified); err != nil {
+		logger.Warnf("Failed to reload tls certificate: %v", err)
+		return
+	}
+	logger.Infof("Reloaded tls certificate %v", r.certFile)
+}
+
+// config returns the tls config of the server, which picks up the current certificates for every connection.
+func (r *CertReloader) config() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the config returned for a client replaces the config of the http server, which would add these protocols.
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*r.cert},
+			ClientCAs:    r.clientCAs,
+			ClientAuth:   r.clientAuth,
+			NextProtos:   base.NextProtos,
+		}, nil
+	}
+	return base
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeServerCert writes a new certificate and key of the server and moves their modification time to modified.
+func writeServerCert(t *testing.T, ca *testCA, name, certFile, keyFile string, modified time.Time) {
+	cert, key := ca.issue(t, name, "peer")
+	for file, content := range map[string][]byte{certFile: cert, keyFile: key} {
+		if err := ioutil.WriteFile(file, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// handshake connects to the server and returns the common name of its certificate and the negotiated protocol.
+func handshake(t *testing.T, address string) (string, string) {
+	conn, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+	return state.PeerCertificates[0].Subject.CommonName, state.NegotiatedProtocol
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, "tlsca.org1.example.com")
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	started := time.Now().Add(-time.Hour)
+	writeServerCert(t, ca, "peer-0.org1.example.com", certFile, keyFile, started)
+
+	certs, err := NewCertReloader(certFile, keyFile, nil, tls.NoClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.NotFoundHandler(), TLSConfig: certs.config()}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+	address := listener.Addr().String()
+
+	name, protocol := handshake(t, address)
+	if name != "peer-0.org1.example.com" {
+		t.Errorf("expected the initial certificate, got %v", name)
+	}
+	if protocol != "h2" {
+		t.Errorf("expected http/2 to be negotiated, got %q", protocol)
+	}
+
+	// unchanged files are not reloaded.
+	certs.reload()
+	if name, _ := handshake(t, address); name != "peer-0.org1.example.com" {
+		t.Errorf("expected the initial certificate, got %v", name)
+	}
+
+	// a rotation in progress keeps the previous certificate.
+	if err := ioutil.WriteFile(keyFile, []byte("partially written"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	certs.reload()
+	if name, _ := handshake(t, address); name != "peer-0.org1.example.com" {
+		t.Errorf("expected the previous certificate to be kept, got %v", name)
+	}
+
+	writeServerCert(t, ca, "peer-1.org1.example.com", certFile, keyFile, started.Add(time.Minute))
+	certs.reload()
+	name, protocol = handshake(t, address)
+	if name != "peer-1.org1.example.com" {
+		t.Errorf("expected the rotated certificate, got %v", name)
+	}
+	if protocol != "h2" {
+		t.Errorf("expected http/2 to be negotiated after the rotation, got %q", protocol)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// Signer signs messages with the identity of an msp folder.
+type Signer struct {
+	MSPID string
+	Cert  []byte // PEM encoded signing certificate
+
+	key *ecdsa.PrivateKey
+}
+
+// NewSigner loads the signing identity from the keystore and signcerts of the given msp folder.
+func NewSigner(mspID, mspConfigPath string) (*Signer, error) {
+	keystore, err := findKeystore(mspConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	signcert, err := findSigncert(mspConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := ioutil.ReadFile(filepath.Join(mspConfigPath, "keystore", keystore))
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := ioutil.ReadFile(filepath.Join(mspConfigPath, "signcerts", signcert))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{MSPID: mspID, Cert: cert, key: key}, nil
+}
+
+// Serialize returns the serialized identity used as creator of proposals and transactions.
+func (s *Signer) Serialize() ([]byte, error) {
+	return proto.Marshal(&msp.SerializedIdentity{Mspid: s.MSPID, IdBytes: s.Cert})
+}
+
+// Sign signs the sha256 digest of the message. The signature is normalized to low-s as required by fabric.
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	halfOrder := new(big.Int).Rsh(s.key.Params().N, 1)
+	if sig.Cmp(halfOrder) > 0 {
+		sig.Sub(s.key.Params().N, sig)
+	}
+
+	return asn1.Marshal(struct{ R, S *big.Int }{r, sig})
+}
+
+func parsePrivateKey(raw []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("Failed to decode private key")
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Private key is not an ecdsa key")
+	}
+	return ecKey, nil
+}
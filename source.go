@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Source represents the source code of a chaincode to be packaged for one of the language runtimes of the peer.
+type Source struct {
+	Type    string `json:"type"`              // golang, node or java
+	Path    string `json:"path,omitempty"`    // import path of golang chaincode
+	Dir     string `json:"dir,omitempty"`     // directory on a mounted volume containing the source
+	Archive []byte `json:"archive,omitempty"` // gzipped tar of the source
+}
+
+// Validate checks the language type and that exactly one of dir or archive is given.
+func (s *Source) Validate() error {
+	s.Type = strings.ToLower(s.Type)
+	switch s.Type {
+	case "golang", "node", "java":
+	case "go":
+		s.Type = "golang"
+	default:
+		return fmt.Errorf("Unsupported chaincode type %v", s.Type)
+	}
+
+	if s.Type == "golang" && s.Path == "" {
+		return fmt.Errorf("Path is required for golang chaincode")
+	}
+	if (s.Dir == "") == (len(s.Archive) == 0) {
+		return fmt.Errorf("Either a source directory or a source archive is required")
+	}
+
+	if root := os.Getenv("SOURCE_ROOT"); s.Dir != "" && (root == "" || !within(root, s.Dir)) {
+		// source directories are denied unless a source root is configured.
+		logger.Warnf("Rejected source directory %v outside of the source root %v", s.Dir, root)
+		return fmt.Errorf("Source directory %v is not within the source root", s.Dir)
+	}
+	return nil
+}
+
+// load archives the source directory, so that the source can be forwarded to the other organizations.
+func (s *Source) load() error {
+	if s.Dir == "" {
+		return nil
+	}
+
+	files, err := readDir(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	if s.Archive, err = buildArchive(files); err != nil {
+		return err
+	}
+	s.Dir = ""
+	return nil
+}
+
+// files returns the source in the code layout expected by the peer.
+func (s *Source) files() ([]File, error) {
+	files, err := readArchive(s.Archive)
+	if err != nil {
+		return nil, err
+	}
+
+	src := "src"
+	if s.Type == "golang" {
+		// golang chaincode is expected within its import path.
+		src = path.Join(src, s.Path)
+	}
+
+	for i, file := range files {
+		if strings.HasPrefix(file.Name, "META-INF/") {
+			// state database indexes are expected next to the source folder.
+			continue
+		}
+		files[i].Name = path.Join(src, file.Name)
+	}
+	return files, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceValidateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "chaincode")
+	for _, name := range []string{filepath.Join(root, "mycc"), filepath.Join(root, "..data"), filepath.Join(dir, "other")} {
+		if err := os.MkdirAll(name, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		root    string
+		dir     string
+		message string
+	}{
+		{name: "dir within root", root: root, dir: filepath.Join(root, "mycc")},
+		{name: "dir starting with dots", root: root, dir: filepath.Join(root, "..data")},
+		{name: "no root", dir: filepath.Join(root, "mycc"), message: "is not within the source root"},
+		{name: "dir outside of root", root: root, dir: filepath.Join(dir, "other"), message: "is not within the source root"},
+		{name: "parent of root", root: root, dir: filepath.Join(root, "mycc", "..", ".."), message: "is not within the source root"},
+	}
+
+	previous := os.Getenv("SOURCE_ROOT")
+	defer os.Setenv("SOURCE_ROOT", previous)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("SOURCE_ROOT", test.root)
+			source := &Source{Type: "node", Dir: test.dir}
+			assertError(t, source.Validate(), test.message)
+		})
+	}
+}
+
+func TestConsentSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		requester string
+		policy    ApprovalPolicy
+		refused   bool
+	}{
+		{name: "local organization", requester: "Org1MSP"},
+		{name: "sources not allowed", requester: "Org2MSP", refused: true},
+		{name: "sources allowed", requester: "Org2MSP", policy: ApprovalPolicy{Sources: true}},
+		{name: "requester not allowed", requester: "Org2MSP", policy: ApprovalPolicy{Sources: true, Requesters: []string{"Org3MSP"}}, refused: true},
+		{name: "manual consent", requester: "Org2MSP", policy: ApprovalPolicy{Sources: true, Manual: true}, refused: true},
+		{name: "unknown requester", policy: ApprovalPolicy{}, refused: true},
+	}
+
+	previous := approvalPolicy
+	defer func() { approvalPolicy = previous }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lifecycle, restore := newTestLifecycle(t, &FakeRunner{}, nil)
+			defer restore()
+			lifecycle.Requester = test.requester
+			lifecycle.Source = &Source{Type: "node"}
+			approvalPolicy = &test.policy
+
+			err := lifecycle.consentSource()
+			if e, ok := err.(*AuthError); ok != test.refused || (ok && e.Status != 403) {
+				t.Errorf("unexpected result %v", err)
+			}
+		})
+	}
+}
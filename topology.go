@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// Topology represents the network of a channel as seen by the discovery service.
+type Topology struct {
+	Channel       string         `json:"channel"`
+	Organizations []Organization `json:"organizations"`
+}
+
+// Organization represents a msp of the channel with its peers and orderers.
+type Organization struct {
+	MSPID    string         `json:"mspid"`
+	Peers    []TopologyPeer `json:"peers"`
+	Orderers []string       `json:"orderers,omitempty"`
+}
+
+// TopologyPeer represents a peer of an organization.
+type TopologyPeer struct {
+	Endpoint     string   `json:"endpoint"`
+	LedgerHeight uint64   `json:"ledger_height"`
+	Chaincodes   []string `json:"chaincodes"`
+}
+
+// Topology groups the discovered peers and orderers by organization. Organizations, peers and orderers are sorted.
+func (d Discovery) Topology(channel string) Topology {
+	organizations := make(map[string]*Organization)
+	organization := func(mspID string) *Organization {
+		if _, ok := organizations[mspID]; !ok {
+			organizations[mspID] = &Organization{MSPID: mspID, Peers: []TopologyPeer{}}
+		}
+		return organizations[mspID]
+	}
+
+	for mspID := range d.Config.MSPs {
+		organization(mspID)
+	}
+	for _, peer := range d.Peers {
+		chaincodes := append([]string{}, peer.Chaincodes...)
+		sort.Strings(chaincodes)
+		org := organization(peer.MSPID)
+		org.Peers = append(org.Peers, TopologyPeer{Endpoint: peer.Endpoint, LedgerHeight: peer.LedgerHeight, Chaincodes: chaincodes})
+	}
+	for mspID, endpoints := range d.Config.Orderers {
+		org := organization(mspID)
+		for _, endpoint := range endpoints.Endpoint {
+			org.Orderers = append(org.Orderers, fmt.Sprintf("%v:%v", endpoint.Host, endpoint.Port))
+		}
+	}
+
+	topology := Topology{Channel: channel, Organizations: []Organization{}}
+	for _, org := range organizations {
+		sort.Slice(org.Peers, func(i, j int) bool { return org.Peers[i].Endpoint < org.Peers[j].Endpoint })
+		sort.Strings(org.Orderers)
+		topology.Organizations = append(topology.Organizations, *org)
+	}
+	sort.Slice(topology.Organizations, func(i, j int) bool {
+		return topology.Organizations[i].MSPID < topology.Organizations[j].MSPID
+	})
+	return topology
+}
+
+// GetTopology returns the organizations, peers and orderers of the channel found by the discovery service.
+func GetTopology(w http.ResponseWriter, req *http.Request) {
+	lifecycle := NewLifecycle(req.Context(), mux.Vars(req))
+
+	discovery, err := lifecycle.discover()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(discovery.Topology(lifecycle.Channel)); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTopology(t *testing.T) {
+	discovery := Discovery{
+		Peers: []DiscoveredPeer{
+			{MSPID: "Org2MSP", Endpoint: "peer-1.peer.org2.example.com:7051", LedgerHeight: 12, Chaincodes: []string{"mycc", "_lifecycle"}},
+			{MSPID: "Org1MSP", Endpoint: "peer-0.peer.org1.example.com:7051", LedgerHeight: 12, Chaincodes: []string{"mycc", "_lifecycle"}},
+			{MSPID: "Org2MSP", Endpoint: "peer-0.peer.org2.example.com:7051", LedgerHeight: 11, Chaincodes: []string{"_lifecycle"}},
+		},
+		Config: ChannelConfig{
+			MSPs: map[string]MSPConfig{"Org1MSP": {}, "Org2MSP": {}, "Org3MSP": {}, "OrdererMSP": {}},
+			Orderers: map[string]OrdererEndpoints{
+				"OrdererMSP": {Endpoint: []OrdererEndpoint{{Host: "orderer2.example.com", Port: 7050}, {Host: "orderer.example.com", Port: 7050}}},
+			},
+		},
+	}
+
+	expected := Topology{
+		Channel: "mychannel",
+		Organizations: []Organization{
+			{MSPID: "OrdererMSP", Peers: []TopologyPeer{}, Orderers: []string{"orderer.example.com:7050", "orderer2.example.com:7050"}},
+			{MSPID: "Org1MSP", Peers: []TopologyPeer{
+				{Endpoint: "peer-0.peer.org1.example.com:7051", LedgerHeight: 12, Chaincodes: []string{"_lifecycle", "mycc"}},
+			}},
+			{MSPID: "Org2MSP", Peers: []TopologyPeer{
+				{Endpoint: "peer-0.peer.org2.example.com:7051", LedgerHeight: 11, Chaincodes: []string{"_lifecycle"}},
+				{Endpoint: "peer-1.peer.org2.example.com:7051", LedgerHeight: 12, Chaincodes: []string{"_lifecycle", "mycc"}},
+			}},
+			{MSPID: "Org3MSP", Peers: []TopologyPeer{}},
+		},
+	}
+
+	if topology := discovery.Topology("mychannel"); !reflect.DeepEqual(topology, expected) {
+		t.Errorf("unexpected topology %+v, want %+v", topology, expected)
+	}
+	if discovery.Peers[0].Chaincodes[0] != "mycc" {
+		t.Errorf("expected the chaincodes of the discovered peer to be left untouched, got %v", discovery.Peers[0].Chaincodes)
+	}
+}
+
+func TestGetTopology(t *testing.T) {
+	fake := (&FakeRunner{}).
+		On(recorded(t, "discover_peers.json"), "", nil, "discover", "peers").
+		On(recorded(t, "discover_config.json"), "", nil, "discover", "config")
+	_, restore := newTestLifecycle(t, fake, nil)
+	defer restore()
+	previousRunner, previousBackend := runner, backend
+	defer func() { runner, backend = previousRunner, previousBackend }()
+	runner, backend = fake, &CLIBackend{Runner: fake}
+
+	req := httptest.NewRequest("GET", "/mychannel/topology", nil)
+	req = mux.SetURLVars(req, map[string]string{"channel": "mychannel"})
+	w := httptest.NewRecorder()
+	GetTopology(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", w.Code, w.Body.String())
+	}
+	var topology Topology
+	if err := json.Unmarshal(w.Body.Bytes(), &topology); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Topology{
+		Channel: "mychannel",
+		Organizations: []Organization{
+			{MSPID: "OrdererMSP", Peers: []TopologyPeer{}, Orderers: []string{"orderer.example.com:7050"}},
+			{MSPID: "Org1MSP", Peers: []TopologyPeer{
+				{Endpoint: "peer-0.peer.org1.example.com:7051", LedgerHeight: 12, Chaincodes: []string{"_lifecycle", "mycc"}},
+			}},
+			{MSPID: "Org2MSP", Peers: []TopologyPeer{
+				{Endpoint: "peer-0.peer.org2.example.com:7051", LedgerHeight: 11, Chaincodes: []string{"_lifecycle"}},
+				{Endpoint: "peer-1.peer.org2.example.com:7051", LedgerHeight: 12, Chaincodes: []string{"_lifecycle", "mycc"}},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(topology, expected) {
+		t.Errorf("unexpected topology %+v, want %+v", topology, expected)
+	}
+}
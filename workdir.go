@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// workdir owns all temporary files of the lifecycle service.
+var workdir = NewWorkDir(filepath.Join(os.TempDir(), "lifecycle"))
+
+// WorkDir manages the temporary files of the lifecycle service below a single root. Operations get their own folder,
+// which is removed once the operation finishes. Files shared between operations, like the root certs of discovered
+// peers, are kept in the shared folder until the service restarts.
+type WorkDir struct {
+	mu         sync.Mutex
+	Root       string
+	operations map[string]*Operation
+}
+
+// Operation represents the temporary folder of a single operation.
+type Operation struct {
+	Name string
+	Dir  string
+
+	workdir *WorkDir
+}
+
+// WorkDirUsage represents the disk usage of the working directory.
+type WorkDirUsage struct {
+	Root       string   `json:"root"`
+	Operations []string `json:"operations"`
+	Files      int      `json:"files"`
+	Bytes      int64    `json:"bytes"`
+}
+
+// NewWorkDir builds a new working directory. The root is created on first use.
+func NewWorkDir(root string) *WorkDir {
+	return &WorkDir{Root: root, operations: make(map[string]*Operation)}
+}
+
+// Clean removes the files left behind by a previous run of the service, e.g. after a crash.
+func (w *WorkDir) Clean() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, dir := range []string{"operations", "shared"} {
+		path := filepath.Join(w.Root, dir)
+		entries, err := ioutil.ReadDir(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if _, ok := w.operations[entry.Name()]; ok {
+				continue
+			}
+			logger.Infof("Removing stale %v", filepath.Join(path, entry.Name()))
+			if err := os.RemoveAll(filepath.Join(path, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Operation creates the temporary folder of a new operation. The folder has to be removed by calling Close.
+func (w *WorkDir) Operation(name string) (*Operation, error) {
+	root := filepath.Join(w.Root, "operations")
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(root, name+"-")
+	if err != nil {
+		return nil, err
+	}
+
+	operation := &Operation{Name: filepath.Base(dir), Dir: dir, workdir: w}
+	w.mu.Lock()
+	w.operations[operation.Name] = operation
+	w.mu.Unlock()
+	return operation, nil
+}
+
+// Shared creates a file in the shared folder, which is kept until the service restarts.
+func (w *WorkDir) Shared(pattern string, data []byte) (string, error) {
+	root := filepath.Join(w.Root, "shared")
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return "", err
+	}
+	return writeTempFile(root, pattern, data)
+}
+
+// Usage walks the working directory and sums up the size of all files.
+func (w *WorkDir) Usage() (WorkDirUsage, error) {
+	usage := WorkDirUsage{Root: w.Root, Operations: []string{}}
+
+	w.mu.Lock()
+	for name := range w.operations {
+		usage.Operations = append(usage.Operations, name)
+	}
+	w.mu.Unlock()
+
+	err := filepath.Walk(w.Root, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			// operations may finish while walking.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			usage.Files++
+			usage.Bytes += info.Size()
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// File writes data to a new file within the folder of the operation and returns its path.
+func (o *Operation) File(pattern string, data []byte) (string, error) {
+	return writeTempFile(o.Dir, pattern, data)
+}
+
+// Close removes the folder of the operation with all its files.
+func (o *Operation) Close() error {
+	o.workdir.mu.Lock()
+	delete(o.workdir.operations, o.Name)
+	o.workdir.mu.Unlock()
+	return os.RemoveAll(o.Dir)
+}
+
+func writeTempFile(dir, pattern string, data []byte) (string, error) {
+	file, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// Status returns the disk usage of the working directory.
+func Status(w http.ResponseWriter, req *http.Request) {
+	usage, err := workdir.Usage()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+		http.Error(w, fmt.Sprintf("Error: %v", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		WorkDir WorkDirUsage `json:"workdir"`
+	}{usage}); err != nil {
+		logger.Error(fmt.Sprintf("Error: %v", err.Error()))
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newTestWorkDir builds a working directory below a new temporary folder. The returned function removes it.
+func newTestWorkDir(t *testing.T) (*WorkDir, func()) {
+	dir, err := ioutil.TempDir("", "lifecycle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWorkDir(filepath.Join(dir, "work")), func() { os.RemoveAll(dir) }
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestOperationIsRemovedOnClose(t *testing.T) {
+	w, remove := newTestWorkDir(t)
+	defer remove()
+
+	install, err := w.Operation("install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	approve, err := w.Operation("approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := install.File("*.tar.gz", []byte("package"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(file) != install.Dir || install.Dir == approve.Dir {
+		t.Fatalf("expected every operation to get its own folder, got %v and %v", file, approve.Dir)
+	}
+
+	if err := install.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if exists(install.Dir) {
+		t.Errorf("expected %v to be removed", install.Dir)
+	}
+	if !exists(approve.Dir) {
+		t.Errorf("expected %v to be kept until the operation is closed", approve.Dir)
+	}
+	if _, ok := w.operations[install.Name]; ok {
+		t.Errorf("expected %v to be forgotten", install.Name)
+	}
+	if err := approve.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCleanRemovesLeftovers(t *testing.T) {
+	w, remove := newTestWorkDir(t)
+	defer remove()
+
+	// files of a previous run.
+	stale, err := NewWorkDir(w.Root).Operation("install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stale.File("*.tar.gz", []byte("package")); err != nil {
+		t.Fatal(err)
+	}
+	shared, err := w.Shared("Org1MSP-*.pem", []byte("root ca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := filepath.Join(w.Root, "approvals.json")
+	if err := ioutil.WriteFile(queue, []byte("[]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	running, err := w.Operation("approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer running.Close()
+
+	if err := w.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{stale.Dir, shared} {
+		if exists(path) {
+			t.Errorf("expected %v to be removed", path)
+		}
+	}
+	for _, path := range []string{running.Dir, queue} {
+		if !exists(path) {
+			t.Errorf("expected %v to be kept", path)
+		}
+	}
+}
+
+func TestCleanWithoutRoot(t *testing.T) {
+	w, remove := newTestWorkDir(t)
+	defer remove()
+
+	if err := w.Clean(); err != nil {
+		t.Errorf("expected a missing root to be clean, got %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	w, remove := newTestWorkDir(t)
+	defer remove()
+	previous := workdir
+	defer func() { workdir = previous }()
+	workdir = w
+
+	install, err := w.Operation("install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer install.Close()
+	if _, err := install.File("*.tar.gz", []byte("package")); err != nil {
+		t.Fatal(err)
+	}
+	done, err := w.Operation("approve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := done.File("*.json", []byte("removed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := done.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Shared("Org1MSP-*.pem", []byte("root ca")); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	rec := httptest.NewRecorder()
+	Status(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", rec.Code, rec.Body.String())
+	}
+
+	var status struct {
+		WorkDir WorkDirUsage `json:"workdir"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(status.WorkDir.Operations)
+	expected := WorkDirUsage{Root: w.Root, Operations: []string{install.Name}, Files: 2, Bytes: int64(len("package") + len("root ca"))}
+	if !reflect.DeepEqual(status.WorkDir, expected) {
+		t.Errorf("unexpected usage %+v, want %+v", status.WorkDir, expected)
+	}
+}